@@ -0,0 +1,384 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"torrProxy/config"
+	"torrProxy/indexers"
+	"torrProxy/logging"
+	"torrProxy/search"
+	"torrProxy/types"
+
+	"go.uber.org/zap"
+)
+
+// reloadTimeout bounds a configuration reload (logins of changed instances).
+const reloadTimeout = 60 * time.Second
+
+// ReloadFunc re-reads the configuration and applies it to the indexers.
+type ReloadFunc func(ctx context.Context) (indexers.Changes, error)
+
+// Admin is what the /admin endpoints operate on.
+type Admin struct {
+	// Token is required as "Authorization: Bearer <token>". When empty the
+	// endpoints only answer loopback and Unix socket clients.
+	Token    string
+	File     *config.File
+	Registry *types.Registry
+	Reload   ReloadFunc
+}
+
+// RegisterAdmin registers the /admin endpoints on the provided mux.
+//
+//	POST   /admin/reload              re-read the config file
+//	GET    /admin/indexers            list configured instances (secrets redacted)
+//	POST   /admin/indexers            add an instance {id, type, name, enabled, settings}
+//	PATCH  /admin/indexers/{id}       change name, enabled and/or settings keys
+//	DELETE /admin/indexers/{id}       remove an instance
+//	POST   /admin/indexers/{id}/test  health/login check, plus a search with ?q=
+//
+// Changes are written to the config file and applied like a reload.
+// Requests changing anything must not come from another site's pages (see
+// sameSite), as a tokenless admin API trusts any local browser.
+func RegisterAdmin(mux *http.ServeMux, a *Admin) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, adminOnly(a.Token, h))
+	}
+	mutate := func(pattern string, h http.HandlerFunc) {
+		handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+			if !sameSite(r) {
+				http.Error(w, "cross-site requests are not allowed", http.StatusForbidden)
+				return
+			}
+			h(w, r)
+		})
+	}
+	mutate("POST /admin/reload", a.reloadHandler)
+	handle("GET /admin/indexers", a.listHandler)
+	mutate("POST /admin/indexers", a.createHandler)
+	mutate("PATCH /admin/indexers/{id}", a.updateHandler)
+	mutate("DELETE /admin/indexers/{id}", a.deleteHandler)
+	mutate("POST /admin/indexers/{id}/test", a.testHandler)
+}
+
+// adminIndexer is an instance as shown by the admin API.
+type adminIndexer struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	Name     string         `json:"name,omitempty"`
+	Enabled  bool           `json:"enabled"`
+	Running  bool           `json:"running"`
+	Settings map[string]any `json:"settings"`
+}
+
+// indexerUpdate is the body of create and update requests. Settings keys
+// are merged into the current ones (see config.Indexer.MergeSettings).
+type indexerUpdate struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	Name     *string        `json:"name"`
+	Enabled  *bool          `json:"enabled"`
+	Settings map[string]any `json:"settings"`
+}
+
+func (a *Admin) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), reloadTimeout)
+	defer cancel()
+	changes, err := a.Reload(ctx)
+	if err != nil {
+		logging.L(r.Context()).Error("Config reload failed", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, changes)
+}
+
+func (a *Admin) listHandler(w http.ResponseWriter, r *http.Request) {
+	cfgs, err := a.File.Indexers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := make([]adminIndexer, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		view, err := a.view(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = append(out, view)
+	}
+	writeJSON(w, out)
+}
+
+func (a *Admin) createHandler(w http.ResponseWriter, r *http.Request) {
+	var req indexerUpdate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ID == "" || req.Type == "" {
+		http.Error(w, "id and type are required", http.StatusBadRequest)
+		return
+	}
+	a.edit(w, r, req.ID, func(cfgs []config.Indexer) ([]config.Indexer, error) {
+		if findEntry(cfgs, req.ID) >= 0 {
+			return nil, fmt.Errorf("indexer %q already exists", req.ID)
+		}
+		cfg := config.Indexer{ID: req.ID, Type: req.Type}
+		if err := applyUpdate(&cfg, req); err != nil {
+			return nil, err
+		}
+		return append(cfgs, cfg), nil
+	})
+}
+
+func (a *Admin) updateHandler(w http.ResponseWriter, r *http.Request) {
+	var req indexerUpdate
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	id := r.PathValue("id")
+	a.edit(w, r, id, func(cfgs []config.Indexer) ([]config.Indexer, error) {
+		n := findEntry(cfgs, id)
+		if n < 0 {
+			return nil, errNotFound
+		}
+		if req.Type != "" && req.Type != cfgs[n].Type {
+			return nil, errors.New("type cannot be changed; delete and re-add the instance")
+		}
+		return cfgs, applyUpdate(&cfgs[n], req)
+	})
+}
+
+func (a *Admin) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	a.edit(w, r, "", func(cfgs []config.Indexer) ([]config.Indexer, error) {
+		n := findEntry(cfgs, id)
+		if n < 0 {
+			return nil, errNotFound
+		}
+		return append(cfgs[:n], cfgs[n+1:]...), nil
+	})
+}
+
+var errNotFound = errors.New("indexer not found")
+
+// decodeJSON decodes r's body into v, answering 415 or 400 and returning
+// false when it is not JSON. The media type check also keeps out the
+// text/plain and form posts that pages of other sites can send.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// edit applies fn to the config file's instances, then reloads and writes
+// the edited instance (id) and the resulting changes.
+func (a *Admin) edit(w http.ResponseWriter, r *http.Request, id string, fn func([]config.Indexer) ([]config.Indexer, error)) {
+	err := a.File.UpdateIndexers(func(cfgs []config.Indexer) ([]config.Indexer, error) {
+		cfgs, err := fn(cfgs)
+		if err != nil {
+			return nil, err
+		}
+		if n := findEntry(cfgs, id); n >= 0 {
+			if err := checkEntry(cfgs[n]); err != nil {
+				return nil, err
+			}
+		}
+		return cfgs, nil
+	})
+	switch {
+	case errors.Is(err, errNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), reloadTimeout)
+	defer cancel()
+	changes, err := a.Reload(ctx)
+	if err != nil {
+		logging.L(r.Context()).Error("Config reload failed", zap.Error(err))
+		http.Error(w, "saved, but reload failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	resp := struct {
+		Indexer *adminIndexer    `json:"indexer,omitempty"`
+		Changes indexers.Changes `json:"changes"`
+	}{Changes: changes}
+	if cfgs, err := a.File.Indexers(); err == nil {
+		if n := findEntry(cfgs, id); n >= 0 {
+			if view, err := a.view(cfgs[n]); err == nil {
+				resp.Indexer = &view
+			}
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// testHandler checks the running instance, or a temporary one started from
+// the config file for disabled/failed instances.
+func (a *Admin) testHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), reloadTimeout)
+	defer cancel()
+
+	query := r.URL.Query().Get("q")
+
+	held, release := a.Registry.Acquire(id)
+	defer release()
+	var idx types.Indexer
+	if len(held) > 0 {
+		idx = held[0]
+	} else {
+		cfgs, err := a.File.Indexers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		n := findEntry(cfgs, id)
+		if n < 0 {
+			http.Error(w, errNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		started := time.Now()
+		idx, err = indexers.Start(ctx, cfgs[n])
+		if err != nil {
+			writeJSON(w, search.CheckResult{
+				Health:     err.Error(),
+				Query:      query,
+				Error:      err.Error(),
+				DurationMS: time.Since(started).Milliseconds(),
+			})
+			return
+		}
+		if c, ok := idx.(types.Closer); ok {
+			defer c.Close()
+		}
+	}
+	writeJSON(w, search.Check(ctx, idx, query))
+}
+
+func (a *Admin) view(cfg config.Indexer) (adminIndexer, error) {
+	settings, err := cfg.SettingsMap(true)
+	if err != nil {
+		return adminIndexer{}, err
+	}
+	view := adminIndexer{
+		ID:       cfg.ID,
+		Type:     cfg.Type,
+		Name:     cfg.Name,
+		Enabled:  cfg.IsEnabled(),
+		Settings: settings,
+	}
+	if idx := a.Registry.Find(cfg.ID); idx != nil && strings.EqualFold(idx.Id(), cfg.ID) {
+		view.Running = true
+		view.Name = idx.Name()
+	}
+	return view, nil
+}
+
+func applyUpdate(cfg *config.Indexer, req indexerUpdate) error {
+	if req.Name != nil {
+		cfg.Name = *req.Name
+	}
+	if req.Enabled != nil {
+		enabled := *req.Enabled
+		cfg.Enabled = &enabled
+	}
+	if req.Settings != nil {
+		return cfg.MergeSettings(req.Settings)
+	}
+	return nil
+}
+
+// checkEntry builds (without starting) an instance to validate its type and
+// settings.
+func checkEntry(cfg config.Indexer) error {
+	idx, err := indexers.New(cfg)
+	if err != nil {
+		return err
+	}
+	if c, ok := idx.(types.Closer); ok {
+		_ = c.Close()
+	}
+	return nil
+}
+
+func findEntry(cfgs []config.Indexer, id string) int {
+	for n, cfg := range cfgs {
+		if strings.EqualFold(cfg.ID, id) {
+			return n
+		}
+	}
+	return -1
+}
+
+// adminOnly guards h with the admin token, or to loopback clients when the
+// token is empty.
+func adminOnly(token string, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			if !isLocal(r) {
+				http.Error(w, "admin endpoints are local-only without server.admin_token", http.StatusForbidden)
+				return
+			}
+		} else {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		h(w, r)
+	})
+}
+
+type unixConnKey struct{}
+
+// ConnContext is meant for http.Server.ConnContext: it marks requests that
+// arrive over a Unix socket, which count as local for the admin endpoints
+// (the socket's file permissions guard them).
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if c.LocalAddr().Network() == "unix" {
+		return context.WithValue(ctx, unixConnKey{}, true)
+	}
+	return ctx
+}
+
+// isLocal reports whether r comes from the loopback interface or a Unix
+// socket.
+func isLocal(r *http.Request) bool {
+	if unix, _ := r.Context().Value(unixConnKey{}).(bool); unix {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"torrProxy/config"
+	"torrProxy/indexers"
+	"torrProxy/types"
+)
+
+func TestAdminRejectsCrossSite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("indexers:\n  - id: fake\n    type: fake\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloads := 0
+	mux := http.NewServeMux()
+	RegisterAdmin(mux, &Admin{
+		File:     config.NewFile(path),
+		Registry: types.NewRegistry(),
+		Reload: func(context.Context) (indexers.Changes, error) {
+			reloads++
+			return indexers.Changes{}, nil
+		},
+	})
+
+	const body = `{"id": "other", "type": "fake"}`
+	tests := []struct {
+		name, method, contentType, fetchSite string
+		want                                 int
+	}{
+		{"cross-site create", "POST", "text/plain", "cross-site", http.StatusForbidden},
+		{"cross-site JSON create", "POST", "application/json", "cross-site", http.StatusForbidden},
+		{"cross-site reload", "POST", "", "cross-site", http.StatusForbidden},
+		{"cross-site delete", "DELETE", "", "cross-site", http.StatusForbidden},
+		{"text/plain create", "POST", "text/plain", "same-origin", http.StatusUnsupportedMediaType},
+		{"cross-site list", "GET", "", "cross-site", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "http://127.0.0.1:8090/admin/indexers"
+			switch {
+			case strings.HasSuffix(tt.name, "reload"):
+				target = "http://127.0.0.1:8090/admin/reload"
+			case tt.method == "DELETE":
+				target += "/fake"
+			}
+			r := httptest.NewRequest(tt.method, target, strings.NewReader(body))
+			r.RemoteAddr = "127.0.0.1:40000"
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+	if reloads != 0 {
+		t.Errorf("rejected requests reloaded the config %d times", reloads)
+	}
+	if cfgs, err := config.NewFile(path).Indexers(); err != nil || len(cfgs) != 1 {
+		t.Errorf("config file indexers = %v (%v), want it unchanged", cfgs, err)
+	}
+
+	// the same request from the admin's own tools goes through
+	r := httptest.NewRequest("POST", "http://127.0.0.1:8090/admin/indexers", strings.NewReader(body))
+	r.RemoteAddr = "127.0.0.1:40000"
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || reloads != 1 {
+		t.Errorf("create = %d with %d reloads, want 200 and 1 (%s)", w.Code, reloads, strings.TrimSpace(w.Body.String()))
+	}
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+	"torrProxy/clients"
+	"torrProxy/indexers"
+	"torrProxy/logging"
+	"torrProxy/metrics"
+	"torrProxy/types"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxTorrentSize bounds the .torrent files fetched to hand to a client.
+	maxTorrentSize = 10 << 20
+	// maxFormSize bounds the form body of /torrproxy/send.
+	maxFormSize = 64 << 10
+)
+
+// RegisterClients registers the download-client endpoints:
+//
+//	GET  /torrproxy/clients  configured clients
+//	POST /torrproxy/send     send a result to a client
+//	GET  /torrproxy/stream   stream a result through a TorrServer client
+//
+// send and stream take client=<id> and either url=<a result's torrent_url>
+// (a magnet or a /torrproxy/download link) or indexer and dl_url. send reads
+// them from the query string or a form body and also takes category,
+// save_path and tags overriding the client's defaults. Cross-site browser
+// requests are rejected (see sameSite), so other web pages cannot add
+// torrents.
+// /torrproxy/stream answers with the torrent's files and their stream URLs
+// or, given index=<file index>, redirects to that file's stream; client may
+// be omitted when a single streaming client is configured.
+func RegisterClients(mux *http.ServeMux, reg *types.Registry, set *clients.Set) {
+	mux.HandleFunc("GET /torrproxy/clients", func(w http.ResponseWriter, r *http.Request) {
+		type clientInfo struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+			Name string `json:"name"`
+		}
+		out := []clientInfo{}
+		for _, c := range set.All() {
+			out = append(out, clientInfo{ID: c.ID(), Type: c.Type(), Name: c.Name()})
+		}
+		writeJSON(w, out)
+	})
+	mux.HandleFunc("POST /torrproxy/send", metrics.Instrument("send", func(w http.ResponseWriter, r *http.Request) {
+		sendHandler(w, r, reg, set)
+	}))
+	mux.HandleFunc("GET /torrproxy/stream", metrics.Instrument("stream", func(w http.ResponseWriter, r *http.Request) {
+		streamHandler(w, r, reg, set)
+	}))
+}
+
+func sendHandler(w http.ResponseWriter, r *http.Request, reg *types.Registry, set *clients.Set) {
+	if !sameSite(r) {
+		http.Error(w, "cross-site requests are not allowed", http.StatusForbidden)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+	id := q.Get("client")
+	if id == "" {
+		http.Error(w, "missing client", http.StatusBadRequest)
+		return
+	}
+	client := set.Get(id)
+	if client == nil {
+		http.Error(w, "client not found: "+id, http.StatusNotFound)
+		return
+	}
+	t, code, err := fetchTorrent(ctx, reg, q)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	opts := clients.Options{Category: q.Get("category"), SavePath: q.Get("save_path")}
+	for _, tag := range strings.Split(q.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+	if err := client.Add(ctx, t, opts); err != nil {
+		logging.L(r.Context()).Warn("Failed to send torrent to client", zap.String("client", client.ID()), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	kind := "torrent"
+	if t.Magnet != "" {
+		kind = "magnet"
+	}
+	logging.L(r.Context()).Info("Sent torrent to client", zap.String("client", client.ID()), zap.String("kind", kind), zap.String("name", t.Name))
+	writeJSON(w, map[string]string{"status": "ok", "client": client.ID(), "kind": kind})
+}
+
+func streamHandler(w http.ResponseWriter, r *http.Request, reg *types.Registry, set *clients.Set) {
+	// a GET for players, but it adds the torrent to TorrServer
+	if !sameSite(r) {
+		http.Error(w, "cross-site requests are not allowed", http.StatusForbidden)
+		return
+	}
+	// a magnet's metadata may take a while to arrive
+	ctx, cancel := context.WithTimeout(r.Context(), 90*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	var streamer clients.Streamer
+	if id := q.Get("client"); id != "" {
+		c := set.Get(id)
+		if c == nil {
+			http.Error(w, "client not found: "+id, http.StatusNotFound)
+			return
+		}
+		s, ok := c.(clients.Streamer)
+		if !ok {
+			http.Error(w, "client cannot stream: "+id, http.StatusBadRequest)
+			return
+		}
+		streamer = s
+	} else {
+		var all []clients.Streamer
+		for _, c := range set.All() {
+			if s, ok := c.(clients.Streamer); ok {
+				all = append(all, s)
+			}
+		}
+		if len(all) != 1 {
+			http.Error(w, fmt.Sprintf("missing client (%d streaming clients configured)", len(all)), http.StatusBadRequest)
+			return
+		}
+		streamer = all[0]
+	}
+	index := -1
+	if v := q.Get("index"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid index: "+v, http.StatusBadRequest)
+			return
+		}
+		index = n
+	}
+
+	t, code, err := fetchTorrent(ctx, reg, q)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	files, err := streamer.Files(ctx, t)
+	if err != nil {
+		logging.L(r.Context()).Warn("Failed to add torrent for streaming", zap.String("client", streamer.ID()), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if index < 0 {
+		writeJSON(w, files)
+		return
+	}
+	for _, f := range files {
+		if f.Index == index {
+			http.Redirect(w, r, f.StreamURL, http.StatusFound)
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("no file with index %d", index), http.StatusNotFound)
+}
+
+// sameSite reports whether r may come from torrProxy's own pages: browsers
+// mark cross-site requests with Sec-Fetch-Site or, when older, an Origin of
+// another host. Clients that send neither (curl, players) are not browsers
+// acting for another site and are allowed.
+func sameSite(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default: // same-site, cross-site
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := neturl.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// fetchTorrent resolves the url (or indexer and dl_url) parameters of q to a
+// magnet or to the .torrent file, downloaded through the indexer that listed
+// it. On failure it also returns the HTTP status to answer with.
+func fetchTorrent(ctx context.Context, reg *types.Registry, q neturl.Values) (clients.Torrent, int, error) {
+	id, dlURL := q.Get("indexer"), q.Get("dl_url")
+	if link := q.Get("url"); link != "" {
+		if strings.HasPrefix(link, "magnet:") {
+			t := clients.Torrent{Magnet: link}
+			if u, err := neturl.Parse(link); err == nil {
+				t.Name = u.Query().Get("dn")
+			}
+			return t, 0, nil
+		}
+		// only torrProxy's own links are followed, through their indexer
+		u, err := neturl.Parse(link)
+		if err == nil {
+			id, dlURL = u.Query().Get("indexer"), u.Query().Get("dl_url")
+		}
+		if err != nil || !strings.HasSuffix(u.Path, "/torrproxy/download") || id == "" || dlURL == "" {
+			return clients.Torrent{}, http.StatusBadRequest, fmt.Errorf("url is neither a magnet nor a /torrproxy/download link")
+		}
+	}
+	if id == "" || dlURL == "" {
+		return clients.Torrent{}, http.StatusBadRequest, fmt.Errorf("missing url, or indexer and dl_url")
+	}
+
+	held, release := reg.Acquire(id)
+	defer release()
+	if len(held) == 0 {
+		return clients.Torrent{}, http.StatusBadRequest, fmt.Errorf("indexer not found: %s", id)
+	}
+	resp, err := indexers.Download(ctx, held[0], dlURL)
+	if err != nil {
+		return clients.Torrent{}, http.StatusBadGateway, fmt.Errorf("failed to download torrent: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return clients.Torrent{}, http.StatusBadGateway, fmt.Errorf("torrent download returned %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxTorrentSize+1))
+	if err != nil {
+		return clients.Torrent{}, http.StatusBadGateway, fmt.Errorf("failed to download torrent: %w", err)
+	}
+	// a bencoded dictionary; trackers answer an expired session with a page
+	if len(b) > maxTorrentSize || !bytes.HasPrefix(b, []byte("d")) {
+		return clients.Torrent{}, http.StatusBadGateway, fmt.Errorf("indexer %s did not return a .torrent file", held[0].Id())
+	}
+
+	name := "torrProxy.torrent"
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	return clients.Torrent{File: b, Name: name}, 0, nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"torrProxy/clients"
+	"torrProxy/config"
+	"torrProxy/types"
+)
+
+func TestSameSite(t *testing.T) {
+	tests := []struct {
+		name, fetchSite, origin string
+		want                    bool
+	}{
+		{"no browser headers", "", "", true},
+		{"same origin", "same-origin", "http://tp.lan:8090", true},
+		{"typed in the address bar", "none", "", true},
+		{"cross site", "cross-site", "http://evil.example", false},
+		{"same site, other origin", "same-site", "http://other.tp.lan", false},
+		{"old browser, same origin", "", "http://tp.lan:8090", true},
+		{"old browser, cross site", "", "http://evil.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://tp.lan:8090/torrproxy/send", nil)
+			if tt.fetchSite != "" {
+				r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := sameSite(r); got != tt.want {
+				t.Errorf("sameSite = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendParams(t *testing.T) {
+	var added []string
+	qbit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			io.WriteString(w, "Ok.")
+		case "/api/v2/torrents/add":
+			added = append(added, r.FormValue("urls")+" "+r.FormValue("category"))
+			io.WriteString(w, "Ok.")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer qbit.Close()
+	set := clients.NewSet()
+	if err := set.Apply([]config.Client{{ID: "qbit", Type: "qbittorrent", URL: qbit.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	RegisterClients(mux, types.NewRegistry(), set)
+
+	const magnet = "magnet:?xt=urn:btih:abcdef&dn=Show"
+	tests := []struct {
+		name, query, body string
+		want              int
+		added             string
+	}{
+		{"query string", "?client=qbit&category=tv&url=" + neturl.QueryEscape(magnet), "", http.StatusOK, magnet + " tv"},
+		{"client in the query, torrent in the body", "?client=qbit", "category=tv&url=" + neturl.QueryEscape(magnet), http.StatusOK, magnet + " tv"},
+		{"form body", "", "client=qbit&url=" + neturl.QueryEscape(magnet), http.StatusOK, magnet + " "},
+		{"no client", "?url=" + neturl.QueryEscape(magnet), "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added = nil
+			r := httptest.NewRequest("POST", "/torrproxy/send"+tt.query, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, strings.TrimSpace(w.Body.String()))
+			}
+			if tt.added != "" && (len(added) != 1 || added[0] != tt.added) {
+				t.Errorf("added %q, want %q", added, tt.added)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"torrProxy/indexers"
+)
+
+// RegisterHealth registers the health endpoints on the provided mux.
+//
+//	/health           liveness: 200 while the server is up
+//	/health/indexers  readiness: cached per-indexer checks; 503 when no
+//	                  indexer is healthy
+func RegisterHealth(mux *http.ServeMux, monitor *indexers.Monitor) {
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/health/indexers", func(w http.ResponseWriter, r *http.Request) {
+		status := monitor.Status()
+		healthy := 0
+		for _, h := range status {
+			if h.OK() {
+				healthy++
+			}
+		}
+		if healthy == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeJSON(w, status)
+	})
+}
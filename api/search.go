@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"torrProxy/metrics"
+	"torrProxy/search"
+	"torrProxy/types"
+)
+
+// searchTimeout bounds one aggregated search across all indexers.
+const searchTimeout = 15 * time.Second
+
+type searchAPI struct {
+	registry *types.Registry
+	aliases  *search.Aliases
+}
+
+// RegisterSearch registers the JSON /search, /recent and /indexers endpoints
+// on the provided mux.
+func RegisterSearch(mux *http.ServeMux, reg *types.Registry, aliases *search.Aliases) {
+	s := &searchAPI{registry: reg, aliases: aliases}
+	mux.HandleFunc("/search", metrics.Instrument("search", s.searchHandler))
+	mux.HandleFunc("/recent", metrics.Instrument("recent", s.recentHandler))
+	mux.HandleFunc("/indexers", s.indexersHandler)
+}
+
+// /indexers lists the running indexers as [{"id": ..., "name": ...}], for
+// building the indexers= parameter.
+func (s *searchAPI) indexersHandler(w http.ResponseWriter, r *http.Request) {
+	type indexerInfo struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	out := make([]indexerInfo, 0)
+	for _, idx := range s.registry.All() {
+		out = append(out, indexerInfo{ID: idx.Id(), Name: idx.Name()})
+	}
+	writeJSON(w, out)
+}
+
+// /search?q=ubuntu&indexers=amigosshare,fake&dedupe=hash&sort=seeders&limit=50
+// If indexers param is omitted, search all indexers.
+// dedupe merges the same release across indexers: all (default), hash or off.
+// Each indexer is queried once per title alias of q (see search/alias.go).
+// Filter, sort and pagination parameters are documented in search/options.go;
+// the response is {"total": <matches before pagination>, "results": [...]},
+// and total is also sent as X-Total-Count.
+func (s *searchAPI) searchHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "missing q parameter", http.StatusBadRequest)
+		return
+	}
+	s.serve(w, r, q)
+}
+
+// /recent?indexers=amigosshare&cat=5000&limit=50
+// Lists the newest torrents of each indexer (RSS sync). Accepts the same
+// parameters as /search except q; results default to newest first.
+func (s *searchAPI) recentHandler(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, "")
+}
+
+// searchResponse is the JSON body of /search and /recent.
+type searchResponse struct {
+	Total   int           `json:"total"`
+	Results []search.Item `json:"results"`
+}
+
+// serve runs a search (or a recent listing when q is empty) and writes the
+// filtered, sorted page as JSON.
+func (s *searchAPI) serve(w http.ResponseWriter, r *http.Request, q string) {
+	dedupe, err := search.ParseDedupeMode(r.URL.Query().Get("dedupe"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := search.ParseOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	toSearch, release := search.SelectIndexers(s.registry, r.URL.Query().Get("indexers"))
+	defer release()
+	if len(toSearch) == 0 {
+		http.Error(w, "no matching indexers found", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	flat, errs := search.Collect(ctx, toSearch, s.aliases, q, dedupe, opts)
+	if q == "" && r.URL.Query().Get("sort") == "" {
+		opts.Sort = search.SortPubDate
+	}
+
+	// If everything failed, return an error
+	if len(flat) == 0 && len(errs) > 0 {
+		http.Error(w, "all backends failed: "+strings.Join(errs, " | "), http.StatusBadGateway)
+		return
+	}
+
+	page, total := search.Apply(flat, opts)
+
+	if page == nil {
+		page = []search.Item{}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, searchResponse{Total: total, Results: page})
+}
@@ -5,22 +5,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	neturl "net/url"
 	"time"
 	"torrProxy/indexers"
+	"torrProxy/metrics"
 	"torrProxy/types"
 )
 
 // RegisterTorrProxyDownload registers the single download endpoint on the provided mux.
 // Call this from your main (after mux is created).
-func RegisterTorrProxyDownload(mux *http.ServeMux) {
-	mux.HandleFunc("/torrproxy/download", torrProxyDownloadHandler)
+func RegisterTorrProxyDownload(mux *http.ServeMux, reg *types.Registry) {
+	mux.HandleFunc("/torrproxy/download", metrics.Instrument("download", func(w http.ResponseWriter, r *http.Request) {
+		torrProxyDownloadHandler(w, r, reg)
+	}))
 }
 
-func torrProxyDownloadHandler(w http.ResponseWriter, r *http.Request) {
+func torrProxyDownloadHandler(w http.ResponseWriter, r *http.Request, reg *types.Registry) {
 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 	defer cancel()
 
+	// per-indexer download metrics; the label is only set to known ids
+	rec := &metrics.StatusRecorder{ResponseWriter: w}
+	w = rec
+	indexerLabel := "unknown"
+	defer func() {
+		metrics.ObserveDownload(indexerLabel, rec.Code(), rec.Bytes())
+	}()
+
 	indexerParam := r.URL.Query().Get("indexer")
 	dlURL := r.URL.Query().Get("dl_url")
 	if indexerParam == "" || dlURL == "" {
@@ -28,50 +38,17 @@ func torrProxyDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	idx := types.FindIndexer(indexerParam)
-	if idx == nil {
+	held, release := reg.Acquire(indexerParam)
+	defer release()
+	if len(held) == 0 {
 		http.Error(w, "indexer not found: "+indexerParam, http.StatusBadRequest)
 		return
 	}
-
-	// Resolve details URL and choose client (use indexer's logged-in client when possible)
-	client := http.DefaultClient
-	baseURL := ""
-	switch v := idx.(type) {
-	case *indexers.AmigosShareIndexer:
-		v.EnsureClient()
-		if v.Client != nil {
-			client = v.Client
-		}
-		baseURL = v.BaseURL
-	case *indexers.RedeTorrent:
-		if v.Client != nil {
-			client = v.Client
-		}
-		baseURL = v.BaseURL
-	case *indexers.CapybaraBRAPIIndexer:
-		if v.Client != nil {
-			client = v.Client
-		}
-		baseURL = v.BaseURL
-	default:
-		// fallback to default
-	}
-
-	// Ensure detailsURL absolute if possible
-	u, err := neturl.Parse(dlURL)
-	if err != nil || !u.IsAbs() {
-		if baseURL != "" {
-			if base, err := neturl.Parse(baseURL); err == nil {
-				dlURL = base.ResolveReference(u).String()
-			}
-		}
-	}
+	idx := held[0]
+	indexerLabel = idx.Id()
 
 	// Fetch the torrent file and stream back using the indexer's client (so cookies preserved)
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, dlURL, nil)
-	req.Header.Set("User-Agent", "torrProxy/0.1")
-	resp, err := client.Do(req)
+	resp, err := indexers.Download(ctx, idx, dlURL)
 	if err != nil {
 		http.Error(w, "failed to download torrent: "+err.Error(), http.StatusBadGateway)
 		return
@@ -0,0 +1,271 @@
+package api
+
+// Minimal Torznab endpoint for Sonarr/Radarr/Prowlarr.
+//
+//	/torznab/api?t=caps
+//	/torznab/api?t=search&q=...&cat=2000,5000&limit=100&offset=0
+//	/torznab/api?t=tvsearch&q=...&season=1&ep=2
+//	/torznab/api?t=movie&q=...&imdbid=tt0317248
+//	/torznab/{indexer}/api?... (same, restricted to one indexer)
+//
+// A search without q (Sonarr's RSS sync) returns the indexers' newest torrents.
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"torrProxy/metrics"
+	"torrProxy/search"
+	"torrProxy/types"
+)
+
+// RegisterTorznab registers the Torznab endpoints on the provided mux.
+func RegisterTorznab(mux *http.ServeMux, reg *types.Registry, aliases *search.Aliases) {
+	s := &searchAPI{registry: reg, aliases: aliases}
+	mux.HandleFunc("/torznab/api", metrics.Instrument("torznab", s.torznabHandler))
+	mux.HandleFunc("/torznab/{indexer}/api", metrics.Instrument("torznab", s.torznabHandler))
+}
+
+func (s *searchAPI) torznabHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	indexerList := r.PathValue("indexer")
+	if indexerList == "" {
+		indexerList = params.Get("indexers")
+	}
+
+	switch t := params.Get("t"); t {
+	case "caps":
+		writeXML(w, torznabCaps())
+		return
+	case "search", "tvsearch", "tv-search", "movie", "movie-search":
+	default:
+		writeTorznabError(w, 202, "no such function: "+t)
+		return
+	}
+
+	opts, err := search.ParseOptions(params)
+	if err != nil {
+		writeTorznabError(w, 201, err.Error())
+		return
+	}
+	toSearch, release := search.SelectIndexers(s.registry, indexerList)
+	defer release()
+	if len(toSearch) == 0 {
+		writeTorznabError(w, 201, "no matching indexers found")
+		return
+	}
+
+	q := torznabQuery(params.Get("q"), params.Get("season"), params.Get("ep"), params.Get("imdbid"))
+	if q == "" {
+		opts.Sort = search.SortPubDate
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	flat, errs := search.Collect(ctx, toSearch, s.aliases, q, search.DedupeHash, opts)
+	if len(flat) == 0 && len(errs) > 0 {
+		writeTorznabError(w, 900, "all backends failed: "+strings.Join(errs, " | "))
+		return
+	}
+	page, _ := search.Apply(flat, opts)
+
+	feed := torznabRSS{
+		Version:      "2.0",
+		XMLNSAtom:    "http://www.w3.org/2005/Atom",
+		XMLNSTorznab: "http://torznab.com/schemas/2015/feed",
+		Channel: torznabChannel{
+			Title:       "torrProxy",
+			Description: "torrProxy Torznab feed",
+		},
+	}
+	for _, it := range page {
+		feed.Channel.Items = append(feed.Channel.Items, torznabItemOf(it))
+	}
+	writeXML(w, feed)
+}
+
+// torznabQuery builds the search text from Torznab parameters: season/ep
+// become an SxxEyy marker and imdbid is used (for alias lookup) when q is empty.
+func torznabQuery(q, season, ep, imdbid string) string {
+	q = strings.TrimSpace(q)
+	if q == "" && imdbid != "" {
+		if !strings.HasPrefix(imdbid, "tt") {
+			imdbid = "tt" + imdbid
+		}
+		q = imdbid
+	}
+	if q == "" {
+		return ""
+	}
+	if n, err := strconv.Atoi(season); err == nil && n > 0 {
+		q += fmt.Sprintf(" S%02d", n)
+		if e, err := strconv.Atoi(ep); err == nil && e > 0 {
+			q += fmt.Sprintf("E%02d", e)
+		}
+	}
+	return q
+}
+
+type torznabRSS struct {
+	XMLName      xml.Name       `xml:"rss"`
+	Version      string         `xml:"version,attr"`
+	XMLNSAtom    string         `xml:"xmlns:atom,attr"`
+	XMLNSTorznab string         `xml:"xmlns:torznab,attr"`
+	Channel      torznabChannel `xml:"channel"`
+}
+
+type torznabChannel struct {
+	Title       string        `xml:"title"`
+	Description string        `xml:"description"`
+	Items       []torznabItem `xml:"item"`
+}
+
+type torznabItem struct {
+	Title       string           `xml:"title"`
+	GUID        string           `xml:"guid"`
+	Link        string           `xml:"link"`
+	Comments    string           `xml:"comments,omitempty"`
+	PubDate     string           `xml:"pubDate,omitempty"`
+	Size        int64            `xml:"size,omitempty"`
+	Description string           `xml:"description,omitempty"`
+	Categories  []int            `xml:"category"`
+	Enclosure   torznabEnclosure `xml:"enclosure"`
+	Attrs       []torznabAttr    `xml:"torznab:attr"`
+}
+
+type torznabEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type torznabAttr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func torznabItemOf(it search.Item) torznabItem {
+	// RedeTorrent lists every magnet of a page under the same Link, so the
+	// detail page only identifies a release when nothing better is known
+	guid := it.Link
+	switch {
+	case it.InfoHash != "":
+		guid = strings.ToLower(it.InfoHash)
+	case it.TorrentURL != "":
+		guid = it.TorrentURL
+	}
+	out := torznabItem{
+		Title:       it.Title,
+		GUID:        guid,
+		Link:        it.TorrentURL,
+		Comments:    it.Link,
+		Size:        it.SizeBytes,
+		Description: it.Description,
+		Enclosure: torznabEnclosure{
+			URL:    it.TorrentURL,
+			Length: it.SizeBytes,
+			Type:   "application/x-bittorrent",
+		},
+	}
+	if !it.PubDate.IsZero() {
+		out.PubDate = it.PubDate.Format(time.RFC1123Z)
+	}
+	cats := []int{}
+	if it.Category != 0 {
+		cats = append(cats, int(it.Category))
+		if !it.Category.IsParent() {
+			cats = append(cats, int(it.Category.Parent()))
+		}
+	}
+	out.Categories = cats
+
+	attr := func(name, value string) {
+		out.Attrs = append(out.Attrs, torznabAttr{Name: name, Value: value})
+	}
+	for _, c := range cats {
+		attr("category", strconv.Itoa(c))
+	}
+	attr("seeders", strconv.Itoa(it.Seeders))
+	attr("peers", strconv.Itoa(it.Seeders+it.Leechers))
+	if it.InfoHash != "" {
+		attr("infohash", it.InfoHash)
+	}
+	if strings.HasPrefix(it.TorrentURL, "magnet:") {
+		attr("magneturl", it.TorrentURL)
+	}
+	if it.Free {
+		attr("downloadvolumefactor", "0")
+	} else {
+		attr("downloadvolumefactor", "1")
+	}
+	attr("uploadvolumefactor", "1")
+	return out
+}
+
+type torznabCapsDoc struct {
+	XMLName xml.Name `xml:"caps"`
+	Server  struct {
+		Title string `xml:"title,attr"`
+	} `xml:"server"`
+	Limits struct {
+		Max     int `xml:"max,attr"`
+		Default int `xml:"default,attr"`
+	} `xml:"limits"`
+	Searching struct {
+		Search      torznabSearchCap `xml:"search"`
+		TVSearch    torznabSearchCap `xml:"tv-search"`
+		MovieSearch torznabSearchCap `xml:"movie-search"`
+	} `xml:"searching"`
+	Categories []torznabCategory `xml:"categories>category"`
+}
+
+type torznabSearchCap struct {
+	Available       string `xml:"available,attr"`
+	SupportedParams string `xml:"supportedParams,attr"`
+}
+
+type torznabCategory struct {
+	ID      int               `xml:"id,attr"`
+	Name    string            `xml:"name,attr"`
+	Subcats []torznabCategory `xml:"subcat"`
+}
+
+func torznabCaps() torznabCapsDoc {
+	var caps torznabCapsDoc
+	caps.Server.Title = "torrProxy"
+	caps.Limits.Max = 100
+	caps.Limits.Default = 100
+	caps.Searching.Search = torznabSearchCap{Available: "yes", SupportedParams: "q"}
+	caps.Searching.TVSearch = torznabSearchCap{Available: "yes", SupportedParams: "q,season,ep"}
+	caps.Searching.MovieSearch = torznabSearchCap{Available: "yes", SupportedParams: "q,imdbid"}
+	for _, c := range types.AllCategories {
+		if c.IsParent() {
+			caps.Categories = append(caps.Categories, torznabCategory{ID: int(c), Name: c.Name()})
+			continue
+		}
+		parent := &caps.Categories[len(caps.Categories)-1]
+		parent.Subcats = append(parent.Subcats, torznabCategory{ID: int(c), Name: c.Name()})
+	}
+	return caps
+}
+
+func writeTorznabError(w http.ResponseWriter, code int, description string) {
+	writeXML(w, struct {
+		XMLName     xml.Name `xml:"error"`
+		Code        int      `xml:"code,attr"`
+		Description string   `xml:"description,attr"`
+	}{Code: code, Description: description})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles is the browser UI: a search page over /search and /indexers.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// RegisterUI serves the embedded web UI at / (assets under /ui/).
+func RegisterUI(mux *http.ServeMux) {
+	sub, _ := fs.Sub(uiFiles, "ui")
+	files := http.FileServerFS(sub)
+	mux.Handle("GET /ui/", http.StripPrefix("/ui", files))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, sub, "index.html")
+	})
+}
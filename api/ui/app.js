@@ -0,0 +1,209 @@
+// torrProxy web UI: searches /search and renders a sortable result table;
+// results can be sent to the download clients of /torrproxy/clients.
+"use strict";
+
+const $ = (sel) => document.querySelector(sel);
+
+// Newznab parent categories (see types/category.go)
+const categories = { 1000: "Console", 2000: "Movies", 3000: "Audio", 4000: "PC", 5000: "TV", 6000: "XXX", 7000: "Books", 8000: "Other" };
+
+let results = [];
+let sortKey = "seeders";
+let sortAsc = false;
+
+async function loadIndexers() {
+  const box = $("#indexers");
+  try {
+    const resp = await fetch("/indexers");
+    for (const idx of await resp.json()) {
+      const label = document.createElement("label");
+      const input = document.createElement("input");
+      input.type = "checkbox";
+      input.value = idx.id;
+      input.checked = true;
+      label.append(input, " ", idx.name);
+      box.append(label);
+    }
+  } catch (err) {
+    box.append("could not load indexers: " + err);
+  }
+}
+
+async function loadClients() {
+  try {
+    const resp = await fetch("/torrproxy/clients");
+    const clients = await resp.json();
+    const select = $("#client");
+    for (const c of clients) select.append(new Option(c.name, c.id));
+    $("#send-to").hidden = clients.length === 0;
+  } catch (err) {
+    // no clients: results only link to their torrents
+  }
+}
+
+async function send(r, button) {
+  const client = $("#client").value;
+  const params = new URLSearchParams({ client, url: r.torrent_url });
+  button.disabled = true;
+  try {
+    const resp = await fetch("/torrproxy/send", { method: "POST", body: params });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || resp.statusText);
+    button.textContent = "sent";
+    setStatus("Sent " + r.title + " to " + $("#client").selectedOptions[0].text + ".");
+  } catch (err) {
+    button.disabled = false;
+    setStatus("Send failed: " + err.message, true);
+  }
+}
+
+function selectedIndexers() {
+  const all = [...document.querySelectorAll("#indexers input")];
+  const checked = all.filter((i) => i.checked).map((i) => i.value);
+  // every box checked means "all", which also covers indexers added later
+  return checked.length === all.length ? "" : checked.join(",");
+}
+
+async function search(ev) {
+  ev.preventDefault();
+  const params = new URLSearchParams({ q: $("#q").value.trim(), limit: "200" });
+  const indexers = selectedIndexers();
+  if (indexers) params.set("indexers", indexers);
+  if ($("#cat").value) params.set("cat", $("#cat").value);
+  if ($("#free").checked) params.set("free", "1");
+
+  setStatus("Searching…");
+  $("#results").hidden = true;
+  let total;
+  try {
+    const resp = await fetch("/search?" + params);
+    if (!resp.ok) throw new Error((await resp.text()).trim() || resp.statusText);
+    ({ total, results } = await resp.json());
+  } catch (err) {
+    setStatus("Search failed: " + err.message, true);
+    return;
+  }
+  let status = total === 0 ? "No results." : total + " result" + (total === 1 ? "" : "s");
+  if (results.length < total) status += ", showing the first " + results.length;
+  setStatus(status);
+  render();
+}
+
+function setStatus(text, error = false) {
+  const el = $("#status");
+  el.textContent = text;
+  el.classList.toggle("error", error);
+}
+
+// numericKeys are the columns whose zero values are omitted from the JSON.
+const numericKeys = new Set(["size_bytes", "seeders", "leechers"]);
+
+function compare(a, b) {
+  const c = numericKeys.has(sortKey)
+    ? (a[sortKey] ?? 0) - (b[sortKey] ?? 0)
+    : String(a[sortKey] ?? "").localeCompare(String(b[sortKey] ?? ""));
+  return sortAsc ? c : -c;
+}
+
+function render() {
+  const body = $("#results tbody");
+  body.replaceChildren(...[...results].sort(compare).map(row));
+  for (const th of document.querySelectorAll("th[data-sort]")) {
+    th.classList.toggle("asc", th.dataset.sort === sortKey && sortAsc);
+    th.classList.toggle("desc", th.dataset.sort === sortKey && !sortAsc);
+  }
+  $("#results").hidden = results.length === 0;
+}
+
+function row(r) {
+  const tr = document.createElement("tr");
+
+  const title = cell("title");
+  title.append(r.link ? link(r.link, r.title) : r.title);
+  if (r.free) title.append(badge("FREE", "free"));
+  if (r.category) title.append(badge(categories[Math.floor(r.category / 1000) * 1000] || r.category));
+  tr.append(title);
+
+  const sources = (r.sources || []).map((s) => s.indexer);
+  tr.append(cell("", sources.length ? sources.join(", ") : r.source || ""));
+  tr.append(cell("num", r.size || formatSize(r.size_bytes)));
+
+  const seeders = cell("num", String(r.seeders || 0));
+  seeders.classList.add(!r.seeders ? "seeders-0" : r.seeders >= 10 ? "seeders-hi" : "seeders");
+  tr.append(seeders);
+  tr.append(cell("num", String(r.leechers || 0)));
+  tr.append(cell("date", formatDate(r.pubdate)));
+
+  const links = cell("links");
+  if (r.torrent_url && r.torrent_url.startsWith("magnet:")) {
+    links.append(link(r.torrent_url, "magnet", ["magnet:"]));
+  } else if (r.torrent_url) {
+    links.append(link(r.torrent_url, ".torrent"));
+  }
+  if (r.torrent_url && !$("#send-to").hidden) {
+    const button = document.createElement("button");
+    button.type = "button";
+    button.className = "send";
+    button.textContent = "send";
+    button.addEventListener("click", () => send(r, button));
+    links.append(button);
+  }
+  tr.append(links);
+  return tr;
+}
+
+function cell(cls, text) {
+  const td = document.createElement("td");
+  if (cls) td.className = cls;
+  if (text !== undefined) td.textContent = text;
+  return td;
+}
+
+// link builds an anchor, or plain text when href (which comes from the
+// trackers) has another scheme than allowed, e.g. javascript:.
+function link(href, text, schemes = ["http:", "https:"]) {
+  let url;
+  try {
+    url = new URL(href, location.href);
+  } catch {
+    return document.createTextNode(text);
+  }
+  if (!schemes.includes(url.protocol)) return document.createTextNode(text);
+  const a = document.createElement("a");
+  a.href = url.href;
+  a.textContent = text;
+  a.rel = "noreferrer";
+  return a;
+}
+
+function badge(text, cls) {
+  const span = document.createElement("span");
+  span.className = "badge" + (cls ? " " + cls : "");
+  span.textContent = text;
+  return span;
+}
+
+function formatSize(bytes) {
+  if (!bytes) return "";
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let n = bytes, u = 0;
+  while (n >= 1024 && u < units.length - 1) { n /= 1024; u++; }
+  return n.toFixed(u ? 1 : 0) + " " + units[u];
+}
+
+function formatDate(s) {
+  const d = s ? new Date(s) : null;
+  return d && d.getFullYear() > 1 ? d.toLocaleDateString() : "";
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  loadIndexers();
+  loadClients();
+  $("#search").addEventListener("submit", search);
+  for (const th of document.querySelectorAll("th[data-sort]")) {
+    th.addEventListener("click", () => {
+      sortAsc = th.dataset.sort === sortKey ? !sortAsc : th.dataset.sort === "title" || th.dataset.sort === "source";
+      sortKey = th.dataset.sort;
+      render();
+    });
+  }
+});
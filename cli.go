@@ -0,0 +1,510 @@
+package main
+
+// Command-line interface: serve (the default) plus commands to search,
+// list, test and download through the configured indexers without starting
+// the server. They exit with status 1 when an indexer fails, so they can be
+// used in scripts and health checks.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"torrProxy/config"
+	"torrProxy/indexers"
+	"torrProxy/logging"
+	"torrProxy/search"
+	"torrProxy/types"
+)
+
+// Exit statuses.
+const (
+	exitOK      = 0
+	exitFailure = 1 // an indexer failed
+	exitUsage   = 2
+)
+
+const usage = `Usage: torrProxy [-config file] [-v] [command] [arguments]
+
+Commands:
+  serve                          run the HTTP server (default)
+  search [flags] [query]         search the indexers; without a query, list their newest torrents
+  indexers list [-json]          list the configured indexers
+  indexers test [flags] <id>     check an indexer's login and, with -q, search it
+  download [-o file] <indexer> <url>
+  download [-o file] <link>      fetch a .torrent through an indexer (link: a /torrproxy/download URL)
+
+Run "torrProxy <command> -h" for the flags of a command.
+
+Global flags:
+`
+
+// run dispatches the command line and returns the exit status.
+func run(args []string) int {
+	fs := flag.NewFlagSet("torrProxy", flag.ContinueOnError)
+	cfgPath := fs.String("config", defaultEnv("TORRPROXY_CONFIG", "config.yaml"), "configuration file")
+	verbose := fs.Bool("v", false, "log at debug level (commands other than serve only log warnings)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseStatus(err)
+	}
+
+	args = fs.Args()
+	cmd := "serve"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+	if cmd == "serve" {
+		return serve(*cfgPath)
+	}
+	if cmd == "help" {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return exitOK
+	}
+
+	c := &cli{cfgPath: *cfgPath, verbose: *verbose, out: os.Stdout}
+	switch cmd {
+	case "search":
+		return c.search(args)
+	case "indexers":
+		if len(args) > 0 {
+			switch args[0] {
+			case "list":
+				return c.indexersList(args[1:])
+			case "test":
+				return c.indexersTest(args[1:])
+			}
+		}
+		fmt.Fprintln(os.Stderr, "usage: torrProxy indexers list|test")
+		return exitUsage
+	case "download":
+		return c.download(args)
+	}
+	fmt.Fprintf(os.Stderr, "torrProxy: unknown command %q\n\n", cmd)
+	fs.Usage()
+	return exitUsage
+}
+
+// parseStatus maps a flag parsing error to an exit status; -h is not an
+// error.
+func parseStatus(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+type cli struct {
+	cfgPath string
+	verbose bool
+	out     io.Writer
+}
+
+// config loads the configuration and sets up logging for a command: to
+// stderr, at warn level unless -v.
+func (c *cli) config() (*config.Config, error) {
+	cfg, err := config.Load(c.cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.cfgPath, err)
+	}
+	cfg.Log.Level, cfg.Log.Output = "warn", "stderr"
+	if c.verbose {
+		cfg.Log.Level = "debug"
+	}
+	if _, err := logging.Setup(cfg.Log); err != nil {
+		return nil, err
+	}
+	indexers.ExternalURL = cfg.Server.ExternalURL
+	if err := logging.SetupCapture(cfg.Debug); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// errUnknownIndexer is returned by start when a list names an indexer that
+// is not configured.
+var errUnknownIndexer = errors.New("unknown indexer")
+
+// start starts the enabled indexers of cfg; with a non-empty list of ids
+// (comma-separated, matched case-insensitively) only those, to spare the
+// other trackers a login. failed lists the instances that did not start.
+func (c *cli) start(ctx context.Context, cfg *config.Config, list string) (m *indexers.Manager, failed []string, err error) {
+	cfgs := cfg.Indexers
+	if list != "" {
+		var picked []config.Indexer
+		var unknown []string
+		for _, id := range strings.Split(list, ",") {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			n := slices.IndexFunc(cfgs, func(ic config.Indexer) bool { return strings.EqualFold(ic.ID, id) })
+			switch {
+			case n < 0:
+				unknown = append(unknown, id)
+			case !slices.ContainsFunc(picked, func(ic config.Indexer) bool { return ic.ID == cfgs[n].ID }):
+				picked = append(picked, cfgs[n])
+			}
+		}
+		if len(unknown) > 0 || len(picked) == 0 {
+			return nil, nil, fmt.Errorf("%w: %q", errUnknownIndexer, unknown)
+		}
+		cfgs = picked
+	}
+	m = indexers.NewManager()
+	changes, err := m.Apply(ctx, cfgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, changes.Failed, nil
+}
+
+func (c *cli) search(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	list := fs.String("indexers", "", "comma-separated indexer ids (default: all)")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	dedupe := fs.String("dedupe", "", "duplicate merging: all, hash or off (default all)")
+	limit := fs.Int("limit", 50, "maximum number of results")
+	sortKey := fs.String("sort", "", "relevance, seeders, size or pubdate")
+	cat := fs.String("cat", "", "comma-separated Newznab categories")
+	free := fs.Bool("free", false, "freeleech results only")
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed for the whole search")
+	params := url.Values{}
+	fs.Func("param", "extra /search `key=value` parameter, e.g. min_seeders=5 (repeatable)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return errors.New("want key=value")
+		}
+		params.Add(k, v)
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: torrProxy search [flags] [query]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseStatus(err)
+	}
+	q := strings.Join(fs.Args(), " ")
+
+	params.Set("limit", strconv.Itoa(*limit))
+	if *sortKey != "" {
+		params.Set("sort", *sortKey)
+	} else if q == "" {
+		params.Set("sort", search.SortPubDate)
+	}
+	if *cat != "" {
+		params.Set("cat", *cat)
+	}
+	if *free {
+		params.Set("free", "1")
+	}
+	opts, err := search.ParseOptions(params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy search:", err)
+		return exitUsage
+	}
+	mode, err := search.ParseDedupeMode(*dedupe)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy search:", err)
+		return exitUsage
+	}
+
+	cfg, err := c.config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	m, failed, err := c.start(ctx, cfg, *list)
+	if errors.Is(err, errUnknownIndexer) {
+		fmt.Fprintln(os.Stderr, "torrProxy search:", err)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+	defer m.Close()
+
+	// m only runs the listed indexers
+	idxs, release := search.SelectIndexers(m.Registry, "")
+	defer release()
+	if len(idxs) == 0 {
+		fmt.Fprintln(os.Stderr, "torrProxy search: no matching indexers found")
+		return exitFailure
+	}
+	flat, errs := search.Collect(ctx, idxs, search.NewAliases(cfg.Server.AliasesFile), q, mode, opts)
+	page, total := search.Apply(flat, opts)
+
+	if *asJSON {
+		c.writeJSON(page)
+	} else {
+		c.printResults(page, total)
+	}
+	for _, id := range failed {
+		errs = append(errs, id+": failed to start")
+	}
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, "error:", e)
+	}
+	if len(errs) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func (c *cli) printResults(items []search.Item, total int) {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TITLE\tSIZE\tSEED\tLEECH\tDATE\tINDEXER\tFREE")
+	for _, it := range items {
+		date := ""
+		if !it.PubDate.IsZero() {
+			date = it.PubDate.Format("2006-01-02")
+		}
+		free := ""
+		if it.Free {
+			free = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			truncate(it.Title, 80), it.Size, it.Seeders, it.Leechers, date, it.Source, free)
+	}
+	tw.Flush()
+	fmt.Fprintf(c.out, "%d of %d results\n", len(items), total)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func (c *cli) indexersList(args []string) int {
+	fs := flag.NewFlagSet("indexers list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	if err := fs.Parse(args); err != nil {
+		return parseStatus(err)
+	}
+	cfg, err := c.config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+
+	type entry struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	}
+	var list []entry
+	for _, ic := range cfg.Indexers {
+		e := entry{ID: ic.ID, Type: ic.Type, Name: ic.Name, Enabled: ic.IsEnabled()}
+		// building an instance does no I/O and gives its default name
+		if idx, err := indexers.New(ic); err == nil {
+			e.Name = idx.Name()
+			if cl, ok := idx.(types.Closer); ok {
+				_ = cl.Close()
+			}
+		}
+		list = append(list, e)
+	}
+
+	if *asJSON {
+		c.writeJSON(list)
+		return exitOK
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tNAME\tENABLED")
+	for _, e := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", e.ID, e.Type, e.Name, e.Enabled)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+func (c *cli) indexersTest(args []string) int {
+	fs := flag.NewFlagSet("indexers test", flag.ContinueOnError)
+	query := fs.String("q", "", "also search for this query")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	timeout := fs.Duration("timeout", time.Minute, "time allowed for login, check and search")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: torrProxy indexers test [flags] <id>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseStatus(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	id := fs.Arg(0)
+
+	cfg, err := c.config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+	var ic *config.Indexer
+	for n := range cfg.Indexers {
+		if strings.EqualFold(cfg.Indexers[n].ID, id) {
+			ic = &cfg.Indexers[n]
+			break
+		}
+	}
+	if ic == nil {
+		fmt.Fprintf(os.Stderr, "torrProxy: no indexer with id %q in %s\n", id, c.cfgPath)
+		return exitFailure
+	}
+
+	// disabled instances can be tested too
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	started := time.Now()
+	var res search.CheckResult
+	idx, err := indexers.Start(ctx, *ic)
+	if err != nil {
+		res = search.CheckResult{Health: err.Error(), Query: *query, Error: err.Error(), DurationMS: time.Since(started).Milliseconds()}
+	} else {
+		res = search.Check(ctx, idx, *query)
+		if cl, ok := idx.(types.Closer); ok {
+			_ = cl.Close()
+		}
+	}
+
+	if *asJSON {
+		c.writeJSON(res)
+	} else {
+		status := "ok"
+		if !res.OK {
+			status = "FAILED"
+		}
+		fmt.Fprintf(c.out, "%s: %s (%dms)\n", ic.ID, status, res.DurationMS)
+		fmt.Fprintf(c.out, "  health: %s\n", res.Health)
+		if res.Query != "" {
+			fmt.Fprintf(c.out, "  search %q: %d results\n", res.Query, res.Results)
+			for _, title := range res.Sample {
+				fmt.Fprintf(c.out, "    %s\n", title)
+			}
+		}
+		if res.Error != "" {
+			fmt.Fprintf(c.out, "  error: %s\n", res.Error)
+		}
+	}
+	if !res.OK {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func (c *cli) download(args []string) int {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	output := fs.String("o", "-", "output file (- for stdout)")
+	timeout := fs.Duration("timeout", time.Minute, "time allowed for login and download")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: torrProxy download [flags] <indexer> <url>\n       torrProxy download [flags] <torrProxy download link>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return parseStatus(err)
+	}
+	var id, dlURL string
+	switch fs.NArg() {
+	case 1:
+		// a torrent_url as found in search results
+		u, err := url.Parse(fs.Arg(0))
+		if err == nil {
+			id, dlURL = u.Query().Get("indexer"), u.Query().Get("dl_url")
+		}
+		if id == "" || dlURL == "" {
+			fmt.Fprintln(os.Stderr, "torrProxy download: not a /torrproxy/download link; give the indexer and URL")
+			return exitUsage
+		}
+	case 2:
+		id, dlURL = fs.Arg(0), fs.Arg(1)
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, err := c.config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	m, _, err := c.start(ctx, cfg, id)
+	if errors.Is(err, errUnknownIndexer) {
+		fmt.Fprintln(os.Stderr, "torrProxy download:", err)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy:", err)
+		return exitFailure
+	}
+	defer m.Close()
+	held, release := m.Registry.Acquire(id)
+	defer release()
+	if len(held) == 0 {
+		fmt.Fprintf(os.Stderr, "torrProxy download: indexer %q not found or failed to start\n", id)
+		return exitFailure
+	}
+
+	resp, err := indexers.Download(ctx, held[0], dlURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy download:", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		fmt.Fprintf(os.Stderr, "torrProxy download: tracker returned %d: %s\n", resp.StatusCode, b)
+		return exitFailure
+	}
+
+	if *output == "-" {
+		if _, err := io.Copy(c.out, resp.Body); err != nil {
+			fmt.Fprintln(os.Stderr, "torrProxy download:", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy download:", err)
+		return exitFailure
+	}
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "torrProxy download:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func (c *cli) writeJSON(v any) {
+	enc := json.NewEncoder(c.out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
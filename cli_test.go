@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testConfig writes a config with fake indexers and an alias for "Cidade de
+// Deus", so searches for it run two queries.
+func testConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	aliases := filepath.Join(dir, "aliases.json")
+	if err := os.WriteFile(aliases, []byte(`[{"pt": "Cidade de Deus", "en": "City of God"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := `server:
+  aliases_file: ` + aliases + `
+indexers:
+  - id: fake
+    type: fake
+    settings: {count: 2}
+  - id: failing
+    type: fake
+    settings: {count: 2, failure_rate: 1}
+  - id: slow
+    type: fake
+    settings: {count: 2, latency: 10s}
+`
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSearchExitStatus(t *testing.T) {
+	cfg := testConfig(t)
+	stdout := os.Stdout
+	devnull, _ := os.Open(os.DevNull)
+	os.Stdout = devnull
+	t.Cleanup(func() { os.Stdout = stdout; devnull.Close() })
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"ok", []string{"search", "-indexers", "fake", "Cidade de Deus"}, exitOK},
+		{"failing indexer", []string{"search", "-indexers", "fake,failing", "Cidade de Deus"}, exitFailure},
+		{"timeout", []string{"search", "-indexers", "slow", "-timeout", "50ms", "Cidade de Deus"}, exitFailure},
+		{"ids match case-insensitively", []string{"search", "-indexers", "FAKE", "Cidade de Deus"}, exitOK},
+		{"unknown indexer", []string{"search", "-indexers", "nope", "Cidade de Deus"}, exitUsage},
+		{"one unknown indexer", []string{"search", "-indexers", "fake,nope", "Cidade de Deus"}, exitUsage},
+		{"empty indexer list", []string{"search", "-indexers", ",", "Cidade de Deus"}, exitUsage},
+		{"download unknown indexer", []string{"download", "nope", "http://example.invalid/x.torrent"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(append([]string{"-config", cfg}, tt.args...)); got != tt.want {
+				t.Errorf("exit status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package clients sends torrents found by torrProxy to download clients,
+// configured by the `clients` list of the config file.
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"torrProxy/config"
+	"torrProxy/logging"
+	"torrProxy/tracing"
+)
+
+// Torrent is what is sent to a client: a magnet link or a .torrent file.
+type Torrent struct {
+	Magnet string
+	File   []byte
+	Name   string // file name of File, e.g. "Some.Release.torrent"
+}
+
+// Options overrides the client's configured defaults for one torrent; empty
+// fields keep them.
+type Options struct {
+	Category string
+	SavePath string
+	Tags     []string
+}
+
+// Client is a configured download client.
+type Client interface {
+	ID() string
+	Type() string
+	Name() string
+	// Add queues t in the client.
+	Add(ctx context.Context, t Torrent, opts Options) error
+}
+
+// File is a file of a torrent added to a Streamer.
+type File struct {
+	Index     int    `json:"index"` // the streamer's file id
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	StreamURL string `json:"stream_url"`
+}
+
+// Streamer is a client that streams torrents while downloading them.
+type Streamer interface {
+	Client
+	// Files adds t, waits for its metadata and returns its files.
+	Files(ctx context.Context, t Torrent) ([]File, error)
+}
+
+// Factory builds a client from its config entry.
+type Factory func(cfg config.Client) (Client, error)
+
+var factories = map[string]Factory{
+	"qbittorrent": newQBittorrent,
+	"torrserver":  newTorrServer,
+}
+
+// New builds the client of a config entry.
+func New(cfg config.Client) (Client, error) {
+	f, ok := factories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("client %q: unknown type %q", cfg.ID, cfg.Type)
+	}
+	return f(cfg)
+}
+
+// Set holds the configured clients; Apply replaces them on config reload.
+type Set struct {
+	mu      sync.RWMutex
+	clients map[string]Client // by lower-cased id
+}
+
+// NewSet returns an empty set.
+func NewSet() *Set {
+	return &Set{clients: make(map[string]Client)}
+}
+
+// Apply builds the clients of cfgs and replaces the current ones. Nothing
+// changes when an entry is invalid.
+func (s *Set) Apply(cfgs []config.Client) error {
+	next := make(map[string]Client, len(cfgs))
+	var errs []error
+	for _, cfg := range cfgs {
+		c, err := New(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		next[strings.ToLower(cfg.ID)] = c
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.clients = next
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the client with the given id, or nil.
+func (s *Set) Get(id string) Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clients[strings.ToLower(id)]
+}
+
+// All returns the clients sorted by id.
+func (s *Set) All() []Client {
+	s.mu.RLock()
+	out := make([]Client, 0, len(s.clients))
+	for _, c := range s.clients {
+		out = append(out, c)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID() < out[j].ID() })
+	return out
+}
+
+// splitTags splits a comma-separated tag list, dropping empty tags.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// transport logs and traces the requests of client id.
+func transport(id string) http.RoundTripper {
+	return logging.Transport(id, tracing.Transport(nil))
+}
@@ -0,0 +1,173 @@
+package clients
+
+// qBittorrent Web API v2 (qBittorrent 4.1+): a session cookie from
+// /api/v2/auth/login, then multipart POSTs to /api/v2/torrents/add.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+	"torrProxy/config"
+)
+
+// qbitTimeout bounds every Web API request.
+const qbitTimeout = 30 * time.Second
+
+type QBittorrent struct {
+	id       string
+	name     string
+	BaseURL  string
+	Username string // empty when the Web UI skips auth (e.g. for localhost)
+	Password string
+	Defaults Options
+	Client   *http.Client // must keep cookies for the session
+
+	mu       sync.Mutex // serializes logins and adds
+	loggedIn bool
+}
+
+func (q *QBittorrent) ID() string   { return q.id }
+func (q *QBittorrent) Type() string { return "qbittorrent" }
+
+func (q *QBittorrent) Name() string {
+	if q.name != "" {
+		return q.name
+	}
+	return q.id
+}
+
+// Add sends t with the defaults overridden by opts. An expired session is
+// renewed once.
+func (q *QBittorrent) Add(ctx context.Context, t Torrent, opts Options) error {
+	if t.Magnet == "" && len(t.File) == 0 {
+		return errors.New("qbittorrent: nothing to add")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.loggedIn && q.Username != "" {
+		if err := q.login(ctx); err != nil {
+			return err
+		}
+	}
+	code, body, err := q.add(ctx, t, q.merge(opts))
+	if err == nil && code == http.StatusForbidden && q.Username != "" {
+		q.loggedIn = false
+		if err := q.login(ctx); err != nil {
+			return err
+		}
+		code, body, err = q.add(ctx, t, q.merge(opts))
+	}
+	if err != nil {
+		return fmt.Errorf("qbittorrent: %w", err)
+	}
+	// "Fails." is how qBittorrent before 5.0 reports a rejected torrent
+	if code != http.StatusOK || strings.TrimSpace(body) == "Fails." {
+		return fmt.Errorf("qbittorrent: add returned %d: %s", code, strings.TrimSpace(body))
+	}
+	return nil
+}
+
+func (q *QBittorrent) merge(opts Options) Options {
+	out := q.Defaults
+	if opts.Category != "" {
+		out.Category = opts.Category
+	}
+	if opts.SavePath != "" {
+		out.SavePath = opts.SavePath
+	}
+	if len(opts.Tags) > 0 {
+		out.Tags = opts.Tags
+	}
+	return out
+}
+
+func (q *QBittorrent) login(ctx context.Context) error {
+	form := neturl.Values{"username": {q.Username}, "password": {q.Password}}
+	code, body, err := q.post(ctx, "/api/v2/auth/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("qbittorrent: login: %w", err)
+	}
+	switch {
+	case code == http.StatusForbidden:
+		return errors.New("qbittorrent: login: IP banned after too many failed attempts")
+	case code != http.StatusOK || strings.TrimSpace(body) != "Ok.":
+		return fmt.Errorf("qbittorrent: login failed (%d): %s", code, strings.TrimSpace(body))
+	}
+	q.loggedIn = true
+	return nil
+}
+
+func (q *QBittorrent) add(ctx context.Context, t Torrent, opts Options) (int, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if t.Magnet != "" {
+		_ = mw.WriteField("urls", t.Magnet)
+	} else {
+		name := t.Name
+		if name == "" {
+			name = "torrProxy.torrent"
+		}
+		fw, err := mw.CreateFormFile("torrents", name)
+		if err != nil {
+			return 0, "", err
+		}
+		_, _ = fw.Write(t.File)
+	}
+	if opts.Category != "" {
+		_ = mw.WriteField("category", opts.Category)
+	}
+	if opts.SavePath != "" {
+		_ = mw.WriteField("savepath", opts.SavePath)
+	}
+	if len(opts.Tags) > 0 {
+		_ = mw.WriteField("tags", strings.Join(opts.Tags, ","))
+	}
+	if err := mw.Close(); err != nil {
+		return 0, "", err
+	}
+	return q.post(ctx, "/api/v2/torrents/add", mw.FormDataContentType(), &buf)
+}
+
+// post sends a form to the Web API and returns the status and (short) body.
+func (q *QBittorrent) post(ctx context.Context, path, contentType string, body io.Reader) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, qbitTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(q.BaseURL, "/")+path, body)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	// the Web UI's CSRF protection rejects requests whose Referer or Origin
+	// names another host
+	req.Header.Set("Referer", q.BaseURL)
+	resp, err := q.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, string(b), nil
+}
+
+func newQBittorrent(cfg config.Client) (Client, error) {
+	jar, _ := cookiejar.New(nil)
+	return &QBittorrent{
+		id:       cfg.ID,
+		name:     cfg.Name,
+		BaseURL:  cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Defaults: Options{Category: cfg.Category, SavePath: cfg.SavePath, Tags: splitTags(cfg.Tags)},
+		Client:   &http.Client{Jar: jar, Transport: transport(cfg.ID)},
+	}, nil
+}
@@ -0,0 +1,148 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"torrProxy/config"
+)
+
+// fakeQBittorrent is a minimal qBittorrent Web API: one account, one session
+// at a time, and a log of the added torrents.
+type fakeQBittorrent struct {
+	mu     sync.Mutex
+	sid    string
+	logins int
+	added  []map[string]string // form fields, "torrents" holding the file
+}
+
+func (f *fakeQBittorrent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/v2/auth/login":
+		if r.FormValue("username") != "admin" || r.FormValue("password") != "secret" {
+			io.WriteString(w, "Fails.")
+			return
+		}
+		f.logins++
+		f.sid = "sid" + string(rune('0'+f.logins))
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: f.sid, Path: "/"})
+		io.WriteString(w, "Ok.")
+	case "/api/v2/torrents/add":
+		if c, err := r.Cookie("SID"); err != nil || c.Value != f.sid {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields := map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			fields[k] = v[0]
+		}
+		if fhs := r.MultipartForm.File["torrents"]; len(fhs) > 0 {
+			file, _ := fhs[0].Open()
+			b, _ := io.ReadAll(file)
+			file.Close()
+			if !strings.HasPrefix(string(b), "d") {
+				http.Error(w, "Torrent file is not valid", http.StatusUnsupportedMediaType)
+				return
+			}
+			fields["torrents"] = fhs[0].Filename + ":" + string(b)
+		}
+		f.added = append(f.added, fields)
+		io.WriteString(w, "Ok.")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestQBittorrent(t *testing.T, password string) (*QBittorrent, *fakeQBittorrent) {
+	t.Helper()
+	fake := &fakeQBittorrent{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	c, err := New(config.Client{
+		ID: "qbit", Type: "qbittorrent", URL: srv.URL + "/",
+		Username: "admin", Password: password,
+		Category: "tv", SavePath: "/downloads", Tags: "torrProxy, br",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*QBittorrent), fake
+}
+
+func TestQBittorrentAdd(t *testing.T) {
+	q, fake := newTestQBittorrent(t, "secret")
+	ctx := context.Background()
+
+	magnet := "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"
+	if err := q.Add(ctx, Torrent{Magnet: magnet}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Add(ctx, Torrent{File: []byte("d4:infode"), Name: "x.torrent"}, Options{Category: "movies", Tags: []string{"4k"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fake.logins != 1 {
+		t.Errorf("logins = %d, want 1", fake.logins)
+	}
+	if len(fake.added) != 2 {
+		t.Fatalf("added %d torrents, want 2", len(fake.added))
+	}
+	want := []map[string]string{
+		{"urls": magnet, "category": "tv", "savepath": "/downloads", "tags": "torrProxy,br"},
+		{"torrents": "x.torrent:d4:infode", "category": "movies", "savepath": "/downloads", "tags": "4k"},
+	}
+	for n := range want {
+		for k, v := range want[n] {
+			if got := fake.added[n][k]; got != v {
+				t.Errorf("torrent %d: %s = %q, want %q", n, k, got, v)
+			}
+		}
+		if len(fake.added[n]) != len(want[n]) {
+			t.Errorf("torrent %d: fields %v, want %v", n, fake.added[n], want[n])
+		}
+	}
+}
+
+func TestQBittorrentSessionExpired(t *testing.T) {
+	q, fake := newTestQBittorrent(t, "secret")
+	ctx := context.Background()
+	if err := q.Add(ctx, Torrent{Magnet: "magnet:?xt=urn:btih:a"}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	fake.sid = "restarted"
+	fake.mu.Unlock()
+	if err := q.Add(ctx, Torrent{Magnet: "magnet:?xt=urn:btih:b"}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.logins != 2 || len(fake.added) != 2 {
+		t.Errorf("logins = %d, added = %d; want 2 and 2", fake.logins, len(fake.added))
+	}
+}
+
+func TestQBittorrentErrors(t *testing.T) {
+	q, fake := newTestQBittorrent(t, "wrong")
+	err := q.Add(context.Background(), Torrent{Magnet: "magnet:?xt=urn:btih:a"}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("bad password: err = %v", err)
+	}
+
+	q.Password = "secret"
+	err = q.Add(context.Background(), Torrent{File: []byte("<html>login</html>")}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "415") {
+		t.Errorf("invalid torrent: err = %v", err)
+	}
+	if len(fake.added) != 0 {
+		t.Errorf("added %d torrents, want 0", len(fake.added))
+	}
+}
@@ -0,0 +1,204 @@
+package clients
+
+// TorrServer (MatriX) API: torrents are added with POST /torrents or
+// /torrent/upload and their files streamed from
+// /stream/<name>?link=<hash>&index=<id>&play.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"torrProxy/config"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	// torrServerTimeout bounds every API request.
+	torrServerTimeout = 30 * time.Second
+	// torrServerMetadataWait bounds the wait for a magnet's metadata.
+	torrServerMetadataWait = 60 * time.Second
+	// torrServerPoll is how often a torrent without files is asked again.
+	torrServerPoll = 500 * time.Millisecond
+)
+
+type TorrServer struct {
+	id        string
+	name      string
+	BaseURL   string
+	PublicURL string // base of stream links; BaseURL if empty
+	Username  string // HTTP basic auth, when TorrServer runs with --httpauth
+	Password  string
+	Category  string // movie, tv, music or other
+	Client    *http.Client
+}
+
+// torrServerStatus is the torrent status TorrServer answers with.
+type torrServerStatus struct {
+	Hash      string `json:"hash"`
+	Title     string `json:"title"`
+	FileStats []struct {
+		ID     int    `json:"id"`
+		Path   string `json:"path"`
+		Length int64  `json:"length"`
+	} `json:"file_stats"`
+}
+
+func (ts *TorrServer) ID() string   { return ts.id }
+func (ts *TorrServer) Type() string { return "torrserver" }
+
+func (ts *TorrServer) Name() string {
+	if ts.name != "" {
+		return ts.name
+	}
+	return ts.id
+}
+
+// Add adds t to TorrServer's list without waiting for its metadata. Only
+// the category of opts applies.
+func (ts *TorrServer) Add(ctx context.Context, t Torrent, opts Options) error {
+	category := ts.Category
+	if opts.Category != "" {
+		category = opts.Category
+	}
+	_, err := ts.add(ctx, t, category)
+	return err
+}
+
+// Files adds t (adding a torrent twice is harmless) and returns its files,
+// waiting for the metadata of a magnet.
+func (ts *TorrServer) Files(ctx context.Context, t Torrent) ([]File, error) {
+	st, err := ts.add(ctx, t, ts.Category)
+	if err != nil {
+		return nil, err
+	}
+	if st.Hash == "" {
+		return nil, errors.New("torrserver: no hash in the add response")
+	}
+
+	wait, cancel := context.WithTimeout(ctx, torrServerMetadataWait)
+	defer cancel()
+	for len(st.FileStats) == 0 {
+		select {
+		case <-wait.Done():
+			return nil, fmt.Errorf("torrserver: no metadata for %s yet (no peers?)", st.Hash)
+		case <-time.After(torrServerPoll):
+		}
+		if st, err = ts.action(wait, map[string]any{"action": "get", "hash": st.Hash}); err != nil {
+			return nil, err
+		}
+	}
+
+	base := ts.PublicURL
+	if base == "" {
+		base = ts.BaseURL
+	}
+	base = strings.TrimRight(base, "/")
+	files := make([]File, 0, len(st.FileStats))
+	for _, f := range st.FileStats {
+		q := neturl.Values{"link": {st.Hash}, "index": {strconv.Itoa(f.ID)}}
+		files = append(files, File{
+			Index: f.ID,
+			Path:  f.Path,
+			Size:  f.Length,
+			// "play" has no value in TorrServer's links
+			StreamURL: base + "/stream/" + neturl.PathEscape(path.Base(f.Path)) + "?" + q.Encode() + "&play",
+		})
+	}
+	return files, nil
+}
+
+func (ts *TorrServer) add(ctx context.Context, t Torrent, category string) (*torrServerStatus, error) {
+	switch {
+	case t.Magnet != "":
+		return ts.action(ctx, map[string]any{
+			"action":     "add",
+			"link":       t.Magnet,
+			"title":      t.Name,
+			"category":   category,
+			"save_to_db": true,
+		})
+	case len(t.File) > 0:
+		return ts.upload(ctx, t, category)
+	}
+	return nil, errors.New("torrserver: nothing to add")
+}
+
+// action posts a /torrents action.
+func (ts *TorrServer) action(ctx context.Context, body map[string]any) (*torrServerStatus, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return ts.post(ctx, "/torrents", "application/json", bytes.NewReader(b))
+}
+
+func (ts *TorrServer) upload(ctx context.Context, t Torrent, category string) (*torrServerStatus, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	name := t.Name
+	if name == "" {
+		name = "torrProxy.torrent"
+	}
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fw.Write(t.File)
+	_ = mw.WriteField("title", strings.TrimSuffix(name, ".torrent"))
+	_ = mw.WriteField("category", category)
+	_ = mw.WriteField("save", "true")
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return ts.post(ctx, "/torrent/upload", mw.FormDataContentType(), &buf)
+}
+
+func (ts *TorrServer) post(ctx context.Context, path, contentType string, body io.Reader) (*torrServerStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, torrServerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(ts.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if ts.Username != "" {
+		req.SetBasicAuth(ts.Username, ts.Password)
+	}
+	resp, err := ts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("torrserver: %w", err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("torrserver: %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	var st torrServerStatus
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("torrserver: %s: %w", path, err)
+	}
+	return &st, nil
+}
+
+func newTorrServer(cfg config.Client) (Client, error) {
+	return &TorrServer{
+		id:        cfg.ID,
+		name:      cfg.Name,
+		BaseURL:   cfg.URL,
+		PublicURL: cfg.PublicURL,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		Category:  cfg.Category,
+		Client:    &http.Client{Transport: transport(cfg.ID)},
+	}, nil
+}
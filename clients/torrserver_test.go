@@ -0,0 +1,125 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"torrProxy/config"
+)
+
+// fakeTorrServer answers like TorrServer MatriX: a magnet gets its files on
+// the first "get" after being added, an uploaded .torrent at once.
+type fakeTorrServer struct {
+	mu      sync.Mutex
+	gets    int
+	actions []map[string]any
+	uploads []string // uploaded file names
+}
+
+const fakeTorrServerStatus = `{"hash":"abc123","title":"Show","file_stats":[
+	{"id":1,"path":"Show/Show.S01E01.mkv","length":1000},
+	{"id":2,"path":"Show/Show S01E02.mkv","length":2000}]}`
+
+func (f *fakeTorrServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "ts" || pass != "pw" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/torrents":
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.actions = append(f.actions, body)
+		if body["action"] == "get" {
+			f.gets++
+			io.WriteString(w, fakeTorrServerStatus)
+			return
+		}
+		io.WriteString(w, `{"hash":"abc123","title":"Show","file_stats":[]}`)
+	case "/torrent/upload":
+		file, fh, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file.Close()
+		f.uploads = append(f.uploads, fh.Filename+" "+r.FormValue("title")+" "+r.FormValue("save"))
+		io.WriteString(w, fakeTorrServerStatus)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestTorrServer(t *testing.T) (*TorrServer, *fakeTorrServer) {
+	t.Helper()
+	fake := &fakeTorrServer{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	c, err := New(config.Client{
+		ID: "ts", Type: "torrserver", URL: srv.URL,
+		PublicURL: "http://tv.lan:8090/", Username: "ts", Password: "pw",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*TorrServer), fake
+}
+
+func TestTorrServerFilesMagnet(t *testing.T) {
+	ts, fake := newTestTorrServer(t)
+	files, err := ts.Files(context.Background(), Torrent{Magnet: "magnet:?xt=urn:btih:abc123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []File{
+		{Index: 1, Path: "Show/Show.S01E01.mkv", Size: 1000, StreamURL: "http://tv.lan:8090/stream/Show.S01E01.mkv?index=1&link=abc123&play"},
+		{Index: 2, Path: "Show/Show S01E02.mkv", Size: 2000, StreamURL: "http://tv.lan:8090/stream/Show%20S01E02.mkv?index=2&link=abc123&play"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for n := range want {
+		if files[n] != want[n] {
+			t.Errorf("file %d = %+v, want %+v", n, files[n], want[n])
+		}
+	}
+	if fake.gets != 1 || fake.actions[0]["action"] != "add" || fake.actions[0]["link"] != "magnet:?xt=urn:btih:abc123" {
+		t.Errorf("actions = %v", fake.actions)
+	}
+}
+
+func TestTorrServerUpload(t *testing.T) {
+	ts, fake := newTestTorrServer(t)
+	files, err := ts.Files(context.Background(), Torrent{File: []byte("d4:infode"), Name: "Show.torrent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || fake.gets != 0 {
+		t.Errorf("got %d files after %d gets, want 2 after 0", len(files), fake.gets)
+	}
+	if len(fake.uploads) != 1 || fake.uploads[0] != "Show.torrent Show true" {
+		t.Errorf("uploads = %q", fake.uploads)
+	}
+}
+
+func TestTorrServerErrors(t *testing.T) {
+	ts, _ := newTestTorrServer(t)
+	ts.Password = "wrong"
+	_, err := ts.Files(context.Background(), Torrent{Magnet: "magnet:?xt=urn:btih:abc123"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("bad password: err = %v", err)
+	}
+	if _, err := ts.Files(context.Background(), Torrent{}); err == nil {
+		t.Error("empty torrent: no error")
+	}
+}
@@ -0,0 +1,463 @@
+package config
+
+// Configuration file loader.
+//
+// torrProxy reads a YAML file (TORRPROXY_CONFIG, default config.yaml) with
+// server settings and a list of indexer instances; see config.example.yaml.
+// Unknown keys and wrongly typed values are rejected at startup. Environment
+// variables override file values:
+//   - the legacy variables from example.env (CAPYBARA_APIKEY, AMIGOS_USERNAME,
+//     ...) apply to the instance whose id equals its type;
+//   - TORRPROXY_<ID>_<SETTING> (e.g. TORRPROXY_CAPYBARA2_API_KEY) applies to
+//     any instance.
+//
+// Without a config file the three built-in indexers are configured from the
+// environment alone, as before.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole configuration file.
+type Config struct {
+	Server   Server    `yaml:"server"`
+	Log      Log       `yaml:"log"`
+	Tracing  Tracing   `yaml:"tracing"`
+	Debug    Debug     `yaml:"debug"`
+	Indexers []Indexer `yaml:"indexers"`
+	Clients  []Client  `yaml:"clients"`
+}
+
+// Server holds the HTTP server settings.
+type Server struct {
+	// Listen is a comma-separated list of addresses to serve on: host:port,
+	// :port or unix:/path/to.sock.
+	Listen      string `yaml:"listen" env:"LISTEN_ADDR"`
+	ExternalURL string `yaml:"external_url" env:"EXTERNAL_URL"`
+	// TLSCert and TLSKey enable HTTPS on TCP addresses. The files are
+	// re-read when they change, so renewed certificates need no restart.
+	TLSCert string `yaml:"tls_cert" env:"TLS_CERT"`
+	TLSKey  string `yaml:"tls_key" env:"TLS_KEY"`
+	// Timeouts of the HTTP server; zero disables one. WriteTimeout bounds
+	// a whole response, including streamed .torrent downloads.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+	// ShutdownTimeout is how long in-flight requests may run after SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	AliasesFile     string        `yaml:"aliases_file" env:"ALIASES_FILE"`
+	// AdminToken guards the /admin endpoints (Authorization: Bearer). When
+	// empty they only answer requests from the loopback interface or a Unix
+	// socket.
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN"`
+	// HealthInterval is how often indexers are probed for /health/indexers.
+	HealthInterval time.Duration `yaml:"health_interval" env:"HEALTH_INTERVAL"`
+}
+
+// Log configures the global logger.
+type Log struct {
+	Level    string `yaml:"level" env:"LOG_LEVEL"`       // debug, info, warn or error
+	Encoding string `yaml:"encoding" env:"LOG_ENCODING"` // console or json
+	Output   string `yaml:"output" env:"LOG_OUTPUT"`     // stderr, stdout or a file path
+}
+
+// Tracing configures OpenTelemetry tracing, exported over OTLP/HTTP.
+type Tracing struct {
+	Enabled bool `yaml:"enabled" env:"TRACING_ENABLED"`
+	// Endpoint is the collector base URL (http://localhost:4318). When empty
+	// the standard OTEL_EXPORTER_OTLP_* variables are used.
+	Endpoint    string `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+}
+
+// Debug configures the capture of raw tracker traffic, for fixing scrapers
+// after a site changes its markup.
+type Debug struct {
+	Capture    bool   `yaml:"capture" env:"DEBUG_CAPTURE"`
+	CaptureDir string `yaml:"capture_dir" env:"DEBUG_CAPTURE_DIR"`
+	// CaptureKeep is how many captured exchanges are kept; older ones are deleted.
+	CaptureKeep int `yaml:"capture_keep" env:"DEBUG_CAPTURE_KEEP"`
+}
+
+// Indexer is one indexer instance. Settings are specific to Type and decoded
+// by the indexer with DecodeSettings.
+type Indexer struct {
+	ID       string    `yaml:"id"`
+	Type     string    `yaml:"type"`
+	Name     string    `yaml:"name,omitempty"`
+	Enabled  *bool     `yaml:"enabled,omitempty"`
+	Settings yaml.Node `yaml:"settings,omitempty"`
+}
+
+// Client is a download client results can be sent to. Category, SavePath
+// and Tags are the defaults of torrents added to it. Any field can be set
+// from TORRPROXY_<ID>_<KEY> (e.g. TORRPROXY_QBIT_PASSWORD).
+type Client struct {
+	ID   string `yaml:"id"`
+	Type string `yaml:"type"` // qbittorrent or torrserver
+	Name string `yaml:"name,omitempty"`
+	URL  string `yaml:"url"`
+	// PublicURL is the base of the stream links given to players when they
+	// reach a TorrServer through another address than torrProxy does.
+	PublicURL string `yaml:"public_url,omitempty"`
+	Username  string `yaml:"username,omitempty"`
+	Password  string `yaml:"password,omitempty"`
+	Category  string `yaml:"category,omitempty"`
+	SavePath  string `yaml:"save_path,omitempty"`
+	Tags      string `yaml:"tags,omitempty"` // comma-separated
+}
+
+// IsEnabled reports whether the instance should be started (default true).
+func (i Indexer) IsEnabled() bool {
+	return i.Enabled == nil || *i.Enabled
+}
+
+// Equal reports whether two instance entries configure the same thing.
+// Settings are compared by content, ignoring formatting and comments.
+func (i Indexer) Equal(o Indexer) bool {
+	return i.ID == o.ID && i.Type == o.Type && i.Name == o.Name &&
+		i.IsEnabled() == o.IsEnabled() && settingsKey(&i.Settings) == settingsKey(&o.Settings)
+}
+
+// settingsKey renders a settings node canonically (sorted keys, no comments).
+func settingsKey(node *yaml.Node) string {
+	if node.IsZero() {
+		return ""
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return ""
+	}
+	b, _ := yaml.Marshal(v)
+	return string(b)
+}
+
+// DefaultServer returns the built-in server settings.
+func DefaultServer() Server {
+	return Server{
+		Listen:            ":8090",
+		ExternalURL:       "http://127.0.0.1:8090",
+		AliasesFile:       "aliases.json",
+		HealthInterval:    5 * time.Minute,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+	}
+}
+
+// Addresses returns the addresses of Listen.
+func (s Server) Addresses() []string {
+	var addrs []string
+	for _, a := range strings.Split(s.Listen, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// Default returns the configuration used when no file exists: one instance
+// of each built-in indexer type.
+func Default() *Config {
+	return &Config{
+		Server:  DefaultServer(),
+		Log:     Log{Level: "info", Encoding: "console", Output: "stderr"},
+		Tracing: Tracing{ServiceName: "torrProxy"},
+		Debug:   Debug{CaptureDir: "debug", CaptureKeep: 200},
+		Indexers: []Indexer{
+			{ID: "amigosshare", Type: "amigosshare"},
+			{ID: "capybarabr", Type: "capybarabr"},
+			{ID: "redetorrent", Type: "redetorrent"},
+		},
+	}
+}
+
+// Load reads and validates the config file at path, applying environment
+// overrides. A missing file yields Default().
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg := Default()
+		return cfg, cfg.finish()
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates a YAML config document.
+func Parse(b []byte) (*Config, error) {
+	def := Default()
+	cfg := &Config{Server: def.Server, Log: def.Log, Tracing: def.Tracing, Debug: def.Debug}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return cfg, cfg.finish()
+}
+
+func (c *Config) finish() error {
+	envTag := func(f reflect.StructField) []string {
+		return []string{f.Tag.Get("env")}
+	}
+	if err := applyEnv(&c.Server, envTag); err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	if err := applyEnv(&c.Log, envTag); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+	if err := applyEnv(&c.Tracing, envTag); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	if err := applyEnv(&c.Debug, envTag); err != nil {
+		return fmt.Errorf("debug: %w", err)
+	}
+	for n := range c.Clients {
+		prefix := "TORRPROXY_" + envName(c.Clients[n].ID) + "_"
+		if err := applyEnv(&c.Clients[n], func(f reflect.StructField) []string {
+			return []string{prefix + envName(yamlKey(f))}
+		}); err != nil {
+			return fmt.Errorf("clients[%d]: %w", n, err)
+		}
+	}
+	return c.Validate()
+}
+
+// Validate checks the settings that do not depend on indexer types.
+func (c *Config) Validate() error {
+	var errs []error
+	if len(c.Server.Addresses()) == 0 {
+		errs = append(errs, errors.New("server.listen: must not be empty"))
+	}
+	for _, a := range c.Server.Addresses() {
+		if path, ok := strings.CutPrefix(a, "unix:"); ok {
+			if path == "" {
+				errs = append(errs, errors.New("server.listen: unix: needs a socket path"))
+			}
+		} else if _, _, err := net.SplitHostPort(a); err != nil {
+			errs = append(errs, fmt.Errorf("server.listen: %w", err))
+		}
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		errs = append(errs, errors.New("server: tls_cert and tls_key must be set together"))
+	}
+	if c.Server.ReadHeaderTimeout < 0 || c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server: timeouts must not be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout: must be positive"))
+	}
+	if c.Server.HealthInterval < time.Second {
+		errs = append(errs, errors.New("server.health_interval: must be at least 1s"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level: unknown level %q", c.Log.Level))
+	}
+	if c.Log.Encoding != "console" && c.Log.Encoding != "json" {
+		errs = append(errs, fmt.Errorf("log.encoding: must be console or json, not %q", c.Log.Encoding))
+	}
+	if c.Log.Output == "" {
+		errs = append(errs, errors.New("log.output: must not be empty"))
+	}
+	if c.Debug.Capture && (c.Debug.CaptureDir == "" || c.Debug.CaptureKeep < 1) {
+		errs = append(errs, errors.New("debug: capture needs a capture_dir and capture_keep >= 1"))
+	}
+	seen := make(map[string]bool)
+	for n, idx := range c.Indexers {
+		switch {
+		case idx.ID == "":
+			errs = append(errs, fmt.Errorf("indexers[%d]: id is required", n))
+		case seen[strings.ToLower(idx.ID)]:
+			errs = append(errs, fmt.Errorf("indexers[%d]: duplicate id %q", n, idx.ID))
+		}
+		seen[strings.ToLower(idx.ID)] = true
+		if idx.Type == "" {
+			errs = append(errs, fmt.Errorf("indexers[%d] (%s): type is required", n, idx.ID))
+		}
+	}
+	seen = make(map[string]bool)
+	for n, cl := range c.Clients {
+		switch {
+		case cl.ID == "":
+			errs = append(errs, fmt.Errorf("clients[%d]: id is required", n))
+		case seen[strings.ToLower(cl.ID)]:
+			errs = append(errs, fmt.Errorf("clients[%d]: duplicate id %q", n, cl.ID))
+		}
+		seen[strings.ToLower(cl.ID)] = true
+		if cl.Type == "" {
+			errs = append(errs, fmt.Errorf("clients[%d] (%s): type is required", n, cl.ID))
+		}
+		if u, err := url.Parse(cl.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("clients[%d] (%s): url must be an absolute http(s) URL", n, cl.ID))
+		}
+		if u, err := url.Parse(cl.PublicURL); cl.PublicURL != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+			errs = append(errs, fmt.Errorf("clients[%d] (%s): public_url must be an absolute http(s) URL", n, cl.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DecodeSettings strictly decodes the instance settings into v (a pointer to
+// a struct pre-filled with defaults) and applies environment overrides.
+// Fields use `yaml` tags for their key and may name a legacy variable with an
+// `env` tag, honored only when the instance id equals its type.
+func (i Indexer) DecodeSettings(v any) error {
+	if !i.Settings.IsZero() {
+		if err := checkKnownKeys(&i.Settings, v); err != nil {
+			return &Error{ID: i.ID, Err: fmt.Errorf("settings: %w", err)}
+		}
+		if err := i.Settings.Decode(v); err != nil {
+			return &Error{ID: i.ID, Err: fmt.Errorf("settings: %w", err)}
+		}
+	}
+	prefix := "TORRPROXY_" + envName(i.ID) + "_"
+	legacy := strings.EqualFold(i.ID, i.Type)
+	if err := applyEnv(v, func(f reflect.StructField) []string {
+		var names []string
+		if legacy {
+			names = append(names, f.Tag.Get("env"))
+		}
+		return append(names, prefix+envName(yamlKey(f)))
+	}); err != nil {
+		return &Error{ID: i.ID, Err: err}
+	}
+	return nil
+}
+
+// Error reports an invalid indexer instance configuration.
+type Error struct {
+	ID  string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("indexer %q: %v", e.ID, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// checkKnownKeys rejects mapping keys that have no matching field in the
+// struct pointed to by v. yaml.Node.Decode has no KnownFields option, and
+// decoding the node directly keeps line numbers relative to the file.
+func checkKnownKeys(node *yaml.Node, v any) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	known := make(map[string]bool)
+	rt := reflect.TypeOf(v).Elem()
+	for n := 0; n < rt.NumField(); n++ {
+		known[yamlKey(rt.Field(n))] = true
+	}
+	var errs []error
+	for n := 0; n+1 < len(node.Content); n += 2 {
+		key := node.Content[n]
+		if known[key.Value] {
+			continue
+		}
+		err := fmt.Errorf("unknown setting %q for %s", key.Value, rt.Name())
+		if key.Line > 0 { // nodes built by MergeSettings have no position
+			err = fmt.Errorf("line %d: %w", key.Line, err)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv overrides the fields of the struct pointed to by v with the
+// environment variables returned by names; later names take precedence.
+func applyEnv(v any, names func(reflect.StructField) []string) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	for n := 0; n < rt.NumField(); n++ {
+		f := rt.Field(n)
+		if !f.IsExported() {
+			continue
+		}
+		for _, name := range names(f) {
+			val, ok := os.LookupEnv(name)
+			if name == "" || !ok || val == "" {
+				continue
+			}
+			if err := setField(rv.Field(n), val); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, val string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", val)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", val)
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", val)
+		}
+		fv.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", val)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func yamlKey(f reflect.StructField) string {
+	if k, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); k != "" {
+		return k
+	}
+	return strings.ToLower(f.Name)
+}
+
+func envName(s string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s))
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret setting values shown by the admin API. Sending it
+// back in an update keeps the stored value.
+const Redacted = "<redacted>"
+
+var secretKeys = []string{"password", "api_key", "apikey", "passkey", "token", "secret", "cookie"}
+
+// IsSecret reports whether a setting key holds a credential.
+func IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SettingsMap decodes the instance settings, replacing secret values with
+// Redacted when redact is set.
+func (i Indexer) SettingsMap(redact bool) (map[string]any, error) {
+	m := make(map[string]any)
+	if !i.Settings.IsZero() {
+		if err := i.Settings.Decode(&m); err != nil {
+			return nil, &Error{ID: i.ID, Err: fmt.Errorf("settings: %w", err)}
+		}
+	}
+	if redact {
+		for k, v := range m {
+			if IsSecret(k) && v != "" && v != nil {
+				m[k] = Redacted
+			}
+		}
+	}
+	return m, nil
+}
+
+// MergeSettings sets the given settings keys. A nil value removes the key
+// and Redacted keeps the current value.
+func (i *Indexer) MergeSettings(changes map[string]any) error {
+	m, err := i.SettingsMap(false)
+	if err != nil {
+		return err
+	}
+	for k, v := range changes {
+		switch v {
+		case nil:
+			delete(m, k)
+		case Redacted:
+		default:
+			m[k] = v
+		}
+	}
+	var node yaml.Node
+	if len(m) > 0 {
+		if err := node.Encode(m); err != nil {
+			return err
+		}
+	}
+	i.Settings = node
+	return nil
+}
+
+// File is the config file of a running server, edited by the admin API.
+// Edits rewrite the indexers list; the rest of the document, comments
+// included, is kept as is.
+type File struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFile returns the config file at path, which need not exist yet.
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+// Indexers returns the instance entries as written in the file, without
+// environment overrides (Default().Indexers if there is no file).
+func (f *File) Indexers() ([]Indexer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, cfg, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Indexers, nil
+}
+
+// UpdateIndexers passes the file's instance entries to fn and writes back
+// the list it returns, after validating it. The file is replaced
+// atomically.
+func (f *File) UpdateIndexers(fn func([]Indexer) ([]Indexer, error)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	doc, cfg, err := f.read()
+	if err != nil {
+		return err
+	}
+	cfg.Indexers, err = fn(cfg.Indexers)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var list yaml.Node
+	if err := list.Encode(cfg.Indexers); err != nil {
+		return err
+	}
+	setMappingKey(doc.Content[0], "indexers", &list)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return f.write(buf.Bytes())
+}
+
+// read parses the file without applying the environment. A missing file
+// yields an empty document holding the default instances.
+func (f *File) read() (*yaml.Node, *Config, error) {
+	def := Default()
+	raw := &Config{Server: def.Server, Log: def.Log, Tracing: def.Tracing, Debug: def.Debug}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		raw.Indexers = Default().Indexers
+		return doc, raw, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	return doc, raw, nil
+}
+
+func (f *File) write(b []byte) error {
+	mode := fs.FileMode(0o600)
+	if st, err := os.Stat(f.Path); err == nil {
+		mode = st.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// setMappingKey sets key to value in a mapping node, appending it if absent.
+func setMappingKey(m *yaml.Node, key string, value *yaml.Node) {
+	for n := 0; n+1 < len(m.Content); n += 2 {
+		if m.Content[n].Value == key {
+			m.Content[n+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
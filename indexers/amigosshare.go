@@ -16,19 +16,36 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	neturl "net/url"
+	"os"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"torrProxy/config"
+	"torrProxy/metrics"
 	"torrProxy/types"
 
 	"github.com/coregx/coregex"
 
 	"github.com/PuerkitoBio/goquery"
+	"go.uber.org/zap"
 )
 
+// AmigosShareConfig is the settings block of an amigosshare indexer.
+type AmigosShareConfig struct {
+	BaseURL   string `yaml:"base_url" env:"AMIGOS_BASE"`
+	Username  string `yaml:"username" env:"AMIGOS_USERNAME"`
+	Password  string `yaml:"password" env:"AMIGOS_PASSWORD"`
+	Freeleech bool   `yaml:"freeleech" env:"AMIGOS_FREELEECH"`
+	Sort      string `yaml:"sort" env:"AMIGOS_SORT"`
+	Order     string `yaml:"order" env:"AMIGOS_ORDER"`
+	MaxPages  int    `yaml:"max_pages" env:"AMIGOS_MAX_PAGES"`
+}
+
 type AmigosShareIndexer struct {
+	ID        string
+	Title     string
 	BaseURL   string
 	Client    *http.Client
 	Username  string
@@ -36,6 +53,7 @@ type AmigosShareIndexer struct {
 	Freeleech bool
 	Sort      string
 	Order     string
+	MaxPages  int // cap on result pages fetched per search
 
 	mu                  sync.RWMutex
 	lastLoginCheck      time.Time
@@ -44,24 +62,27 @@ type AmigosShareIndexer struct {
 }
 
 func (a *AmigosShareIndexer) Name() string {
+	if a.Title != "" {
+		return a.Title
+	}
 	return "Amigos Share Club (ASC)"
 }
 
 func (a *AmigosShareIndexer) Id() string {
+	if a.ID != "" {
+		return a.ID
+	}
 	return "amigosshare"
 }
 
-func newAmigosClient() *http.Client {
+func newAmigosClient(id string) *http.Client {
 	jar, _ := cookiejar.New(nil)
-	return &http.Client{
-		Jar:     jar,
-		Timeout: 20 * time.Second,
-	}
+	return newHTTPClient(id, 20*time.Second, jar)
 }
 
 func (a *AmigosShareIndexer) EnsureClient() {
 	if a.Client == nil {
-		a.Client = newAmigosClient()
+		a.Client = newAmigosClient(a.Id())
 	}
 	// Initialize login check validity period (5 minutes default)
 	if a.loginCheckValid == 0 {
@@ -187,7 +208,7 @@ func (a *AmigosShareIndexer) resolveAction(action string) string {
 //}
 
 // login posts the login form and verifies login.
-func (a *AmigosShareIndexer) login() error {
+func (a *AmigosShareIndexer) login(ctx context.Context) error {
 	if a.Username == "" || a.Password == "" {
 		return nil
 	}
@@ -195,7 +216,7 @@ func (a *AmigosShareIndexer) login() error {
 
 	// 1) GET login page to collect cookies and hidden inputs
 	loginURL := a.resolveAction("account-login.php")
-	reqGet, _ := http.NewRequest(http.MethodGet, loginURL, nil)
+	reqGet, _ := http.NewRequestWithContext(ctx, http.MethodGet, loginURL, nil)
 	reqGet.Header.Set("User-Agent", "jackett-lite/0.1")
 	respGet, err := a.Client.Do(reqGet)
 	if err != nil {
@@ -223,7 +244,7 @@ func (a *AmigosShareIndexer) login() error {
 	formValues.Set("autologout", "yes")
 
 	// POST login
-	reqPost, _ := http.NewRequest(http.MethodPost, loginURL, strings.NewReader(formValues.Encode()))
+	reqPost, _ := http.NewRequestWithContext(ctx, http.MethodPost, loginURL, strings.NewReader(formValues.Encode()))
 	reqPost.Header.Set("User-Agent", "torrProxy/0.1")
 	reqPost.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	reqPost.Header.Set("Referer", loginURL)
@@ -247,17 +268,32 @@ func (a *AmigosShareIndexer) login() error {
 		}
 	}
 
-	// Verify login
-	checkURL, _ := neturl.Parse(a.BaseURL)
+	return a.checkLogin(ctx)
+}
+
+// checkLogin GETs torrents-search.php and verifies the session is logged in
+// (or, without credentials, that the site answers).
+func (a *AmigosShareIndexer) checkLogin(ctx context.Context) error {
+	a.EnsureClient()
+	checkURL, err := neturl.Parse(a.BaseURL)
+	if err != nil {
+		return err
+	}
 	checkURL.Path = path.Join(checkURL.Path, "torrents-search.php")
 
-	req2, _ := http.NewRequest(http.MethodGet, checkURL.String(), nil)
+	req2, _ := http.NewRequestWithContext(ctx, http.MethodGet, checkURL.String(), nil)
 	req2.Header.Set("User-Agent", "torrProxy/0.1")
 	resp2, err := a.Client.Do(req2)
 	if err != nil {
 		return fmt.Errorf("GET check page failed: %w", err)
 	}
 	defer resp2.Body.Close()
+	if resp2.StatusCode >= 400 {
+		return fmt.Errorf("amigosshare: bad response %d", resp2.StatusCode)
+	}
+	if a.Username == "" || a.Password == "" {
+		return nil
+	}
 
 	checkBody, _ := io.ReadAll(resp2.Body)
 	checkStr := strings.ToLower(string(checkBody))
@@ -317,35 +353,80 @@ func (a *AmigosShareIndexer) buildSearchURL(query string) (string, error) {
 }
 
 func (a *AmigosShareIndexer) Search(ctx context.Context, query string) ([]types.Result, error) {
-	a.EnsureClient()
+	return a.SearchPage(ctx, query, types.Page{})
+}
 
+// SearchPage walks torrents-search.php's 0-based `page` parameter to cover
+// the requested window.
+func (a *AmigosShareIndexer) SearchPage(ctx context.Context, query string, page types.Page) ([]types.Result, error) {
 	url, err := a.buildSearchURL(query)
 	if err != nil {
 		return nil, err
 	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	return fetchPages(ctx, page, 0, a.MaxPages, func(n int) ([]types.Result, bool, error) {
+		vals := u.Query()
+		if n > 0 {
+			vals.Set("page", strconv.Itoa(n))
+		}
+		u.RawQuery = vals.Encode()
+		return a.fetchList(ctx, u.String(), n)
+	})
+}
+
+// Recent lists the newest torrents: an empty torrents-search.php query
+// sorted by id, newest first, regardless of the configured sort.
+func (a *AmigosShareIndexer) Recent(ctx context.Context) ([]types.Result, error) {
+	url, err := a.buildSearchURL("")
+	if err != nil {
+		return nil, err
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	vals := u.Query()
+	vals.Set("sort", "id")
+	vals.Set("order", "desc")
+	u.RawQuery = vals.Encode()
+	results, _, err := a.fetchList(ctx, u.String(), 0)
+	return results, err
+}
+
+// fetchList GETs a torrents-search.php page and parses its result rows. more
+// reports whether the pager links to the page after n.
+func (a *AmigosShareIndexer) fetchList(ctx context.Context, url string, n int) (results []types.Result, more bool, err error) {
+	a.EnsureClient()
 
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	req.Header.Set("User-Agent", "torrProxy/1.0")
 
 	resp, err := a.Client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("amigosshare: bad response %d", resp.StatusCode)
+		return nil, false, fmt.Errorf("amigosshare: bad response %d", resp.StatusCode)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	out := make([]types.Result, 0)
 	selector := "div#fancy-list-group ul.list-group li.list-group-item"
 
-	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+	rows := doc.Find(selector)
+	if rows.Length() == 0 {
+		warnIfNoRows(ctx, a.Id(), url, doc)
+	}
+	rows.Each(func(i int, s *goquery.Selection) {
 		// Freeleech filter: YAML used :has(span.badge-success:contains("FREE"))
 		if a.Freeleech && s.Find("span.badge-success:contains('FREE')").Length() == 0 {
 			return
@@ -370,6 +451,12 @@ func (a *AmigosShareIndexer) Search(ctx context.Context, query string) ([]types.
 		// Title filters
 		title = cleanTitle(title, year, quality, language)
 
+		// category from the genre badge (Animes, Documentário...), else guess from title
+		category := categoryFromText(genre)
+		if category == 0 {
+			category = guessCategory(title)
+		}
+
 		downloadVol := 1.0
 		if s.Find(`span.badge-success:contains("FREE")`).Length() > 0 {
 			downloadVol = 0.0
@@ -384,11 +471,13 @@ func (a *AmigosShareIndexer) Search(ctx context.Context, query string) ([]types.
 			Link:        AbsURL(a.BaseURL, detailsHref),
 			Description: genre,
 			Size:        size,
+			SizeBytes:   ParseSize(size),
 			PubDate:     pubDate,
 			Seeders:     seeders,
 			Leechers:    leechers,
 			InfoHash:    "",
 			TorrentURL:  buildTorrProxyDownloadLink(a.Id(), AbsURL(a.BaseURL, downloadHref)),
+			Category:    refineCategory(category, title),
 		}
 
 		if downloadVol == 0.0 {
@@ -398,28 +487,65 @@ func (a *AmigosShareIndexer) Search(ctx context.Context, query string) ([]types.
 		out = append(out, res)
 	})
 
-	return out, nil
+	more = doc.Find(fmt.Sprintf(`a[href$="page=%d"], a[href*="page=%d&"]`, n+1, n+1)).Length() > 0
+	return out, more, nil
 
 }
 
-func init() {
+func newAmigosShare(cfg config.Indexer) (types.Indexer, error) {
+	settings := AmigosShareConfig{
+		BaseURL:  "https://cliente.amigos-share.club/",
+		Sort:     "id",
+		Order:    "desc",
+		MaxPages: 5,
+	}
+	if err := cfg.DecodeSettings(&settings); err != nil {
+		return nil, err
+	}
+	// AMIGOS_FREELECH is the misspelled name read before the config file
+	if v, ok := os.LookupEnv("AMIGOS_FREELECH"); ok && v != "" && os.Getenv("AMIGOS_FREELEECH") == "" && strings.EqualFold(cfg.ID, cfg.Type) {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &config.Error{ID: cfg.ID, Err: fmt.Errorf("AMIGOS_FREELECH: invalid boolean %q", v)}
+		}
+		settings.Freeleech = b
+		zap.L().Warn("AMIGOS_FREELECH is deprecated; set AMIGOS_FREELEECH instead", zap.String("indexer", cfg.ID))
+	}
 	idx := &AmigosShareIndexer{
-		BaseURL:  defaultEnv("AMIGOS_BASE", "https://cliente.amigos-share.club/"),
-		Username: defaultEnv("AMIGOS_USERNAME", ""),
-		Password: defaultEnv("AMIGOS_PASSWORD", ""),
-		Freeleech: func() bool {
-			v, _ := strconv.ParseBool(defaultEnv("AMIGOS_FREELECH", "false"))
-			return v
-		}(),
-		Sort:  defaultEnv("AMIGOS_SORT", "id"),
-		Order: defaultEnv("AMIGOS_ORDER", "desc"),
+		ID:        cfg.ID,
+		Title:     cfg.Name,
+		BaseURL:   settings.BaseURL,
+		Username:  settings.Username,
+		Password:  settings.Password,
+		Freeleech: settings.Freeleech,
+		Sort:      settings.Sort,
+		Order:     settings.Order,
+		MaxPages:  settings.MaxPages,
 	}
 	// ensure we have client with cookiejar
-	idx.Client = newAmigosClient()
-	err := idx.login()
-	if err != nil {
-		return
+	idx.Client = newAmigosClient(idx.Id())
+	return idx, nil
+}
+
+// Init logs in to the tracker.
+func (a *AmigosShareIndexer) Init(ctx context.Context) error {
+	if a.Username == "" || a.Password == "" {
+		return nil
 	}
+	err := a.login(ctx)
+	metrics.ObserveLogin(a.Id(), err)
+	return err
+}
+
+// HealthCheck verifies the tracker answers and the session is still valid.
+func (a *AmigosShareIndexer) HealthCheck(ctx context.Context) error {
+	return a.checkLogin(ctx)
+}
 
-	types.Indexers = append(types.Indexers, idx)
+// Close drops the session's idle connections.
+func (a *AmigosShareIndexer) Close() error {
+	if a.Client != nil {
+		a.Client.CloseIdleConnections()
+	}
+	return nil
 }
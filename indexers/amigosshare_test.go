@@ -0,0 +1,96 @@
+package indexers
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"strings"
+	"testing"
+	"torrProxy/config"
+	"torrProxy/internal/httpfixture"
+)
+
+// newTestAmigosShare returns an AmigosShare indexer talking to the fixture
+// at path. With -record it logs in with AMIGOS_USERNAME/AMIGOS_PASSWORD.
+func newTestAmigosShare(t *testing.T, path string) *AmigosShareIndexer {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	a := &AmigosShareIndexer{
+		BaseURL:  "https://cliente.amigos-share.club/",
+		Username: "tester",
+		Password: "secret",
+		Sort:     "id",
+		Order:    "desc",
+		MaxPages: 1,
+		Client:   &http.Client{Jar: jar, Transport: httpfixture.Transport(t, path)},
+	}
+	if httpfixture.Recording() {
+		a.Username, a.Password = os.Getenv("AMIGOS_USERNAME"), os.Getenv("AMIGOS_PASSWORD")
+	}
+	return a
+}
+
+func TestAmigosShareSearch(t *testing.T) {
+	a := newTestAmigosShare(t, "testdata/amigosshare/search.json")
+	if httpfixture.Recording() {
+		if err := a.Init(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := a.Search(context.Background(), "duna parte dois")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no results")
+	}
+	httpfixture.Golden(t, "testdata/amigosshare/search.golden.json", results)
+}
+
+func TestAmigosShareLogin(t *testing.T) {
+	a := newTestAmigosShare(t, "testdata/amigosshare/login.json")
+	if err := a.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := a.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck after login: %v", err)
+	}
+}
+
+func TestAmigosShareLoginFailed(t *testing.T) {
+	if httpfixture.Recording() {
+		t.Skip("needs invalid credentials; the fixture is kept as is")
+	}
+	a := newTestAmigosShare(t, "testdata/amigosshare/login_failed.json")
+	err := a.Init(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Usuário ou senha incorretos") {
+		t.Fatalf("Init = %v, want the site's alert", err)
+	}
+}
+
+func TestAmigosShareLegacyFreeleechEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		old, new  string
+		freeleech bool
+	}{
+		{"old name", "amigosshare", "true", "", true},
+		{"new name wins", "amigosshare", "true", "false", false},
+		{"other instances", "amigos2", "true", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AMIGOS_FREELECH", tt.old)
+			t.Setenv("AMIGOS_FREELEECH", tt.new)
+			idx, err := newAmigosShare(config.Indexer{ID: tt.id, Type: "amigosshare"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := idx.(*AmigosShareIndexer).Freeleech; got != tt.freeleech {
+				t.Errorf("Freeleech = %v, want %v", got, tt.freeleech)
+			}
+		})
+	}
+}
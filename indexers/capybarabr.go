@@ -1,9 +1,7 @@
 package indexers
 
 // Converted & extended from capybarabr-api.yml (UNIT3D API).
-// Config via env:
-//  - CAPYBARA_APIKEY
-//  - CAPYBARA_BASE (default https://capybarabr.com/)
+// Configured by a `capybarabr` indexer entry (see CapybaraBRConfig).
 
 import (
 	"context"
@@ -11,28 +9,48 @@ import (
 	"fmt"
 	"net/http"
 	neturl "net/url"
-	"os"
 	"path"
 	"strconv"
 	"strings"
 	"time"
+	"torrProxy/config"
 	"torrProxy/types"
 
 	"github.com/coregx/coregex"
 )
 
+// capybaraPerPage is the UNIT3D API page size we request (its maximum).
+const capybaraPerPage = 100
+
+// CapybaraBRConfig is the settings block of a capybarabr indexer.
+type CapybaraBRConfig struct {
+	BaseURL   string `yaml:"base_url" env:"CAPYBARA_BASE"`
+	APIKey    string `yaml:"api_key" env:"CAPYBARA_APIKEY"`
+	Freeleech bool   `yaml:"freeleech" env:"CAPYBARA_FREELEECH"`
+	MaxPages  int    `yaml:"max_pages" env:"CAPYBARA_MAX_PAGES"`
+}
+
 type CapybaraBRAPIIndexer struct {
+	ID        string
+	Title     string
 	BaseURL   string
 	APIKey    string
 	Freeleech bool
+	MaxPages  int // cap on API pages fetched per search
 	Client    *http.Client
 }
 
 func (c *CapybaraBRAPIIndexer) Name() string {
+	if c.Title != "" {
+		return c.Title
+	}
 	return "CapybaraBR (API)"
 }
 
 func (c *CapybaraBRAPIIndexer) Id() string {
+	if c.ID != "" {
+		return c.ID
+	}
 	return "capybarabr"
 }
 
@@ -70,6 +88,11 @@ func intFromInterface(v interface{}) int {
 }
 
 func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]types.Result, error) {
+	return c.SearchPage(ctx, query, types.Page{})
+}
+
+// SearchPage walks the UNIT3D `page` parameter to cover the requested window.
+func (c *CapybaraBRAPIIndexer) SearchPage(ctx context.Context, query string, page types.Page) ([]types.Result, error) {
 	m := coregex.MustCompile("complet")
 	if m.MatchString(strings.ToLower(query)) {
 		return nil, fmt.Errorf("no need to search for packs")
@@ -80,11 +103,37 @@ func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]type
 		return nil, err
 	}
 
+	return fetchPages(ctx, page, capybaraPerPage, c.MaxPages, func(n int) ([]types.Result, bool, error) {
+		qp := u.Query()
+		qp.Set("name", query)
+		qp.Set("perPage", strconv.Itoa(capybaraPerPage))
+		qp.Set("page", strconv.Itoa(n+1))
+		u.RawQuery = qp.Encode()
+		return c.fetch(ctx, u)
+	})
+}
+
+// Recent lists the newest torrents: the filter endpoint without a name,
+// sorted by creation date.
+func (c *CapybaraBRAPIIndexer) Recent(ctx context.Context) ([]types.Result, error) {
+	u, err := c.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
 	qp := u.Query()
-	qp.Set("name", query)
-	qp.Set("perPage", "100")
+	qp.Set("sortField", "created_at")
+	qp.Set("sortDirection", "desc")
+	qp.Set("perPage", strconv.Itoa(capybaraPerPage))
 	u.RawQuery = qp.Encode()
 
+	results, _, err := c.fetch(ctx, u)
+	return results, err
+}
+
+// fetch calls the torrents filter API and maps its JSON to results. more
+// reports whether the API has a next page.
+func (c *CapybaraBRAPIIndexer) fetch(ctx context.Context, u *neturl.URL) (results []types.Result, more bool, err error) {
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if c.APIKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.APIKey)
@@ -94,17 +143,17 @@ func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]type
 
 	resp, err := c.client().Do(req)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("capybarabr: bad response %d", resp.StatusCode)
+		return nil, false, fmt.Errorf("capybarabr: bad response %d", resp.StatusCode)
 	}
 
 	var payload map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	resultsRaw := payload["data"]
@@ -114,7 +163,13 @@ func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]type
 
 	resultsSlice, _ := resultsRaw.([]interface{})
 	if resultsSlice == nil {
-		return nil, fmt.Errorf("capybarabr: unexpected json structure")
+		return nil, false, fmt.Errorf("capybarabr: unexpected json structure")
+	}
+
+	// UNIT3D paginates with links.next; fall back to a full page meaning more
+	more = len(resultsSlice) >= capybaraPerPage
+	if links, ok := payload["links"].(map[string]interface{}); ok {
+		more = types.ToString(links["next"]) != ""
 	}
 
 	out := make([]types.Result, 0, len(resultsSlice))
@@ -137,8 +192,9 @@ func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]type
 			m := coregex.MustCompile("100[%]?")
 			free = m.MatchString(types.ToString(raw))
 		}
+		// the API has no freeleech filter; drop the other results here
 		if c.Freeleech && !free {
-			return nil, fmt.Errorf("not free")
+			continue
 		}
 
 		title := types.ToString(attrs["name"])
@@ -152,30 +208,73 @@ func (c *CapybaraBRAPIIndexer) Search(ctx context.Context, query string) ([]type
 		}
 		pub := ParseDateWithFormats(createdAt, []string{"01/02/2006 15:04:05 -07:00", time.RFC3339, "2006-01-02T15:04:05.000000Z"})
 
+		// category: prefer the name (custom trackers renumber), then UNIT3D ids
+		category := categoryFromText(types.ToString(attrs["category"]))
+		if category == 0 {
+			category = unit3dCategories[toInt(attrs["category_id"])]
+		}
+		if category == 0 {
+			category = guessCategory(title)
+		}
+
+		size := types.ToString(attrs["size"])
 		res := types.Result{
 			Title:      title,
 			Link:       types.ToString(attrs["details_link"]),
-			Size:       types.ToString(attrs["size"]),
+			Size:       size,
+			SizeBytes:  ParseSize(size),
 			Free:       free,
 			PubDate:    pub,
 			Seeders:    toInt(attrs["seeders"]),
 			Leechers:   toInt(attrs["leechers"]),
 			InfoHash:   types.ToString(attrs["info_hash"]),
 			TorrentURL: buildTorrProxyDownloadLink(c.Id(), download),
+			Category:   refineCategory(category, title),
 		}
 
 		out = append(out, res)
 	}
 
-	return out, nil
+	return out, more, nil
+}
+
+func newCapybaraBR(cfg config.Indexer) (types.Indexer, error) {
+	settings := CapybaraBRConfig{
+		BaseURL:  "https://capybarabr.com/",
+		MaxPages: 5,
+	}
+	if err := cfg.DecodeSettings(&settings); err != nil {
+		return nil, err
+	}
+	return &CapybaraBRAPIIndexer{
+		ID:        cfg.ID,
+		Title:     cfg.Name,
+		BaseURL:   settings.BaseURL,
+		APIKey:    settings.APIKey,
+		Freeleech: settings.Freeleech,
+		MaxPages:  settings.MaxPages,
+		Client:    newHTTPClient(cfg.ID, 20*time.Second, nil),
+	}, nil
+}
+
+// HealthCheck asks the API for a single torrent, which also validates the
+// API key.
+func (c *CapybaraBRAPIIndexer) HealthCheck(ctx context.Context) error {
+	u, err := c.buildURL()
+	if err != nil {
+		return err
+	}
+	qp := u.Query()
+	qp.Set("perPage", "1")
+	u.RawQuery = qp.Encode()
+	_, _, err = c.fetch(ctx, u)
+	return err
 }
 
-func init() {
-	base := defaultEnv("CAPYBARA_BASE", "https://capybarabr.com/")
-	apiKey := os.Getenv("CAPYBARA_APIKEY")
-	idx := &CapybaraBRAPIIndexer{
-		BaseURL: base,
-		APIKey:  apiKey,
+// Close drops idle API connections.
+func (c *CapybaraBRAPIIndexer) Close() error {
+	if c.Client != nil {
+		c.Client.CloseIdleConnections()
 	}
-	types.Indexers = append(types.Indexers, idx)
+	return nil
 }
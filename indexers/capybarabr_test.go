@@ -0,0 +1,46 @@
+package indexers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"torrProxy/internal/httpfixture"
+)
+
+func TestCapybaraBRSearch(t *testing.T) {
+	c := &CapybaraBRAPIIndexer{
+		BaseURL:  "https://capybarabr.com/",
+		APIKey:   "test",
+		MaxPages: 1,
+		Client:   &http.Client{Transport: httpfixture.Transport(t, "testdata/capybarabr/search.json")},
+	}
+	if httpfixture.Recording() {
+		c.APIKey = os.Getenv("CAPYBARA_APIKEY")
+	}
+	results, err := c.Search(context.Background(), "duna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no results")
+	}
+	httpfixture.Golden(t, "testdata/capybarabr/search.golden.json", results)
+}
+
+func TestCapybaraBRFreeleechOnly(t *testing.T) {
+	c := &CapybaraBRAPIIndexer{
+		BaseURL:   "https://capybarabr.com/",
+		APIKey:    "test",
+		MaxPages:  1,
+		Freeleech: true,
+		Client:    &http.Client{Transport: httpfixture.Replay(t, "testdata/capybarabr/search.json")},
+	}
+	results, err := c.Search(context.Background(), "duna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Free {
+		t.Errorf("got %d results, want only the freeleech one: %+v", len(results), results)
+	}
+}
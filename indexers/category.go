@@ -0,0 +1,98 @@
+package indexers
+
+// Mapping of tracker categories to the Newznab tree in types.Category.
+//
+// Trackers expose categories differently (UNIT3D category_id/name, badges,
+// WordPress sections), so each indexer feeds whatever it has through
+// categoryFromText and refines the result with the release resolution.
+
+import (
+	"strings"
+	"torrProxy/types"
+
+	"github.com/coregx/coregex"
+)
+
+var (
+	resolutionRe = coregex.MustCompile(`(?i)\b(2160p|1080p|720p|576p|480p|4k|uhd)\b`)
+	tvMarkerRe   = coregex.MustCompile(`(?i)\bS\d{1,2}(E\d{1,3})?\b|\btemporada\b|\bseason\b|\bepis[oó]dio\b`)
+)
+
+// unit3dCategories maps the default UNIT3D category_id values.
+var unit3dCategories = map[int]types.Category{
+	1: types.CategoryMovies,
+	2: types.CategoryTV,
+	3: types.CategoryAudio,
+	4: types.CategoryPCGames,
+	5: types.CategoryPC,
+}
+
+// categoryKeywords maps folded, lowercase words found in tracker category
+// names/badges to categories. Checked in order, so specific entries come first.
+var categoryKeywords = []struct {
+	words []string
+	cat   types.Category
+}{
+	{[]string{"anime", "animes"}, types.CategoryTVAnime},
+	{[]string{"documentario", "documentarios", "documentary"}, types.CategoryTVDocumentary},
+	{[]string{"serie", "series", "tv", "novela", "novelas", "temporada"}, types.CategoryTV},
+	{[]string{"filme", "filmes", "movie", "movies", "cinema"}, types.CategoryMovies},
+	{[]string{"musica", "musicas", "music", "album"}, types.CategoryAudio},
+	{[]string{"jogo", "jogos", "game", "games"}, types.CategoryPCGames},
+	{[]string{"app", "apps", "aplicativo", "aplicativos", "software", "programa", "programas"}, types.CategoryPC},
+	{[]string{"hq", "hqs", "quadrinhos", "comic", "comics", "manga"}, types.CategoryBooksComics},
+	{[]string{"livro", "livros", "ebook", "ebooks", "book", "books"}, types.CategoryBooksEBook},
+	{[]string{"xxx", "adulto"}, types.CategoryXXX},
+}
+
+// Resolution returns the normalized resolution tag of a title ("" if none).
+func Resolution(title string) string {
+	r := strings.ToLower(resolutionRe.FindString(title))
+	if r == "4k" || r == "uhd" {
+		return "2160p"
+	}
+	return r
+}
+
+// categoryFromText maps a tracker category name or badge ("Séries", "Filmes
+// 4K", "Animes") to a category, or 0 if nothing matches.
+func categoryFromText(s string) types.Category {
+	words := strings.Fields(NormalizeQuery(s))
+	for _, kw := range categoryKeywords {
+		for _, w := range words {
+			for _, k := range kw.words {
+				if w == k {
+					return kw.cat
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// guessCategory falls back to the release name: season/episode markers mean
+// TV, anything else is assumed to be a movie.
+func guessCategory(title string) types.Category {
+	if tvMarkerRe.MatchString(title) {
+		return types.CategoryTV
+	}
+	return types.CategoryMovies
+}
+
+// refineCategory narrows Movies/TV to their SD/HD/UHD child using the
+// resolution found in title (Newznab uses the same +30/+40/+45 offsets under
+// both parents). Other categories are returned unchanged.
+func refineCategory(c types.Category, title string) types.Category {
+	if c != types.CategoryMovies && c != types.CategoryTV {
+		return c
+	}
+	switch Resolution(title) {
+	case "2160p":
+		return c + 45
+	case "1080p", "720p":
+		return c + 40
+	case "576p", "480p":
+		return c + 30
+	}
+	return c
+}
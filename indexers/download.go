@@ -0,0 +1,54 @@
+package indexers
+
+// Fetching .torrent files through the indexer that listed them.
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"torrProxy/types"
+)
+
+// Download GETs the .torrent at dlURL, resolved against the indexer's base
+// URL when relative, with idx's HTTP client so its session cookies apply.
+// The caller checks the status and closes the body.
+func Download(ctx context.Context, idx types.Indexer, dlURL string) (*http.Response, error) {
+	client := http.DefaultClient
+	baseURL := ""
+	switch v := idx.(type) {
+	case *AmigosShareIndexer:
+		v.EnsureClient()
+		if v.Client != nil {
+			client = v.Client
+		}
+		baseURL = v.BaseURL
+	case *RedeTorrent:
+		if v.Client != nil {
+			client = v.Client
+		}
+		baseURL = v.BaseURL
+	case *CapybaraBRAPIIndexer:
+		if v.Client != nil {
+			client = v.Client
+		}
+		baseURL = v.BaseURL
+	case *Fake:
+		client = v.Client
+	}
+
+	u, err := neturl.Parse(dlURL)
+	if err != nil || !u.IsAbs() {
+		if baseURL != "" {
+			if base, err := neturl.Parse(baseURL); err == nil {
+				dlURL = base.ResolveReference(u).String()
+			}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dlURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "torrProxy/0.1")
+	return client.Do(req)
+}
@@ -0,0 +1,300 @@
+package indexers
+
+// A synthetic indexer for testing clients (Sonarr, Radarr...) and torrProxy's
+// aggregation, timeouts and pagination offline. Results are derived from the
+// query and seed only, so the same search always returns the same releases,
+// and their .torrent files are generated on download.
+// Configured by a `fake` indexer entry (see FakeConfig).
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"torrProxy/config"
+	"torrProxy/types"
+)
+
+// fakeHost serves the fake indexer's .torrent files; it never resolves.
+const fakeHost = "fake.invalid"
+
+// fakePieceLength keeps the pieces of the generated torrents short.
+const fakePieceLength = 16 << 20
+
+// FakeConfig is the settings block of a fake indexer.
+type FakeConfig struct {
+	Count          int           `yaml:"count"`           // results per search
+	Latency        time.Duration `yaml:"latency"`         // delay of every search and download
+	FailureRate    float64       `yaml:"failure_rate"`    // share of calls failing, 0 to 1
+	FreeleechRatio float64       `yaml:"freeleech_ratio"` // share of freeleech results, 0 to 1
+	Links          string        `yaml:"links"`           // torrent, magnet or mixed
+	Seed           int64         `yaml:"seed"`
+}
+
+type Fake struct {
+	ID             string
+	Title          string
+	Count          int
+	Latency        time.Duration
+	FailureRate    float64
+	FreeleechRatio float64
+	Links          string
+	Seed           int64
+	Client         *http.Client // serves the generated .torrent files
+
+	mu  sync.Mutex
+	rng *rand.Rand // failure rolls, created on first use
+}
+
+func (f *Fake) Name() string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return "Fake"
+}
+
+func (f *Fake) Id() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	return "fake"
+}
+
+func (f *Fake) Search(ctx context.Context, query string) ([]types.Result, error) {
+	return f.SearchPage(ctx, query, types.Page{})
+}
+
+// SearchPage returns the window of the Count results of query; a zero Limit
+// returns all of them.
+func (f *Fake) SearchPage(ctx context.Context, query string, page types.Page) ([]types.Result, error) {
+	if err := f.call(ctx); err != nil {
+		return nil, err
+	}
+	results := f.results(query)
+	if page.Offset >= len(results) {
+		return nil, nil
+	}
+	results = results[page.Offset:]
+	if page.Limit > 0 && len(results) > page.Limit {
+		results = results[:page.Limit]
+	}
+	return results, nil
+}
+
+// Recent returns the results of an empty query.
+func (f *Fake) Recent(ctx context.Context) ([]types.Result, error) {
+	return f.Search(ctx, "")
+}
+
+// HealthCheck fails as often as searches do.
+func (f *Fake) HealthCheck(ctx context.Context) error {
+	return f.call(ctx)
+}
+
+// call waits Latency and then fails with probability FailureRate.
+func (f *Fake) call(ctx context.Context) error {
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	f.mu.Lock()
+	if f.rng == nil {
+		f.rng = rand.New(rand.NewPCG(uint64(f.Seed), 0))
+	}
+	failed := f.rng.Float64() < f.FailureRate
+	f.mu.Unlock()
+	if failed {
+		return errors.New("fake: simulated failure")
+	}
+	return nil
+}
+
+var fakeResolutions = []string{"2160p", "1080p", "1080p", "720p", "480p"}
+var fakeSources = []string{"WEB-DL", "BluRay", "WEBRip", "HDTV"}
+
+// results generates the releases of query, the same ones on every call.
+func (f *Fake) results(query string) []types.Result {
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, NormalizeQuery(query))
+	rng := rand.New(rand.NewPCG(uint64(f.Seed), h.Sum64()))
+
+	name := strings.Join(strings.Fields(query), ".")
+	if name == "" {
+		name = "Fake.Release"
+	}
+	released := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	out := make([]types.Result, 0, f.Count)
+	for i := range f.Count {
+		var title string
+		if i%3 == 2 {
+			title = fmt.Sprintf("%s.S01E%02d", name, i/3+1)
+		} else {
+			title = fmt.Sprintf("%s.%d", name, 2024-i/3%10)
+		}
+		title = fmt.Sprintf("%s.%s.%s.DUAL-FAKE%02d", title,
+			fakeResolutions[rng.IntN(len(fakeResolutions))], fakeSources[rng.IntN(len(fakeSources))], i)
+		size := 200<<20 + rng.Int64N(20<<30)
+		_, infoHash := fakeTorrent(title, size)
+
+		res := types.Result{
+			Title:     title,
+			Link:      fmt.Sprintf("http://%s/%s/details/%d", fakeHost, neturl.PathEscape(f.Id()), i),
+			Size:      formatFakeSize(size),
+			SizeBytes: size,
+			Free:      rng.Float64() < f.FreeleechRatio,
+			PubDate:   released.Add(-time.Duration(i) * 6 * time.Hour),
+			Seeders:   rng.IntN(500),
+			Leechers:  rng.IntN(50),
+			InfoHash:  infoHash,
+			Category:  refineCategory(guessCategory(title), title),
+		}
+		if f.Links == "magnet" || (f.Links == "mixed" && i%2 == 1) {
+			res.TorrentURL = "magnet:?xt=urn:btih:" + infoHash + "&dn=" + neturl.QueryEscape(title)
+		} else {
+			dl := neturl.URL{Scheme: "http", Host: fakeHost, Path: "/" + f.Id() + "/" + strconv.Itoa(i) + ".torrent"}
+			dl.RawQuery = neturl.Values{"name": {title}, "size": {strconv.FormatInt(size, 10)}}.Encode()
+			res.TorrentURL = buildTorrProxyDownloadLink(f.Id(), dl.String())
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+func formatFakeSize(size int64) string {
+	if size >= 1<<30 {
+		return fmt.Sprintf("%.2f GB", float64(size)/(1<<30))
+	}
+	return fmt.Sprintf("%.2f MB", float64(size)/(1<<20))
+}
+
+// fakeTorrent bencodes a single-file torrent for name and returns it with
+// its info hash. Pieces are derived from name, not from real data.
+func fakeTorrent(name string, size int64) (torrent []byte, infoHash string) {
+	pieces := make([]byte, 0, (size/fakePieceLength+1)*sha1.Size)
+	for n := int64(0); n*fakePieceLength < size; n++ {
+		sum := sha1.Sum([]byte(name + "/" + strconv.FormatInt(n, 10)))
+		pieces = append(pieces, sum[:]...)
+	}
+
+	var info bytes.Buffer
+	info.WriteString("d")
+	fmt.Fprintf(&info, "6:lengthi%de", size)
+	fmt.Fprintf(&info, "4:name%d:%s", len(name), name)
+	fmt.Fprintf(&info, "12:piece lengthi%de", fakePieceLength)
+	fmt.Fprintf(&info, "6:pieces%d:", len(pieces))
+	info.Write(pieces)
+	info.WriteString("e")
+	sum := sha1.Sum(info.Bytes())
+
+	var b bytes.Buffer
+	announce := "http://" + fakeHost + "/announce"
+	fmt.Fprintf(&b, "d8:announce%d:%s", len(announce), announce)
+	b.WriteString("10:created by22:torrProxy fake indexer")
+	b.WriteString("4:info")
+	b.Write(info.Bytes())
+	b.WriteString("e")
+	return b.Bytes(), hex.EncodeToString(sum[:])
+}
+
+// fakeTransport answers the fake indexer's download links with generated
+// .torrent files, after the indexer's latency and failure rate.
+type fakeTransport struct {
+	f *Fake
+}
+
+func (t fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if req.URL.Host != fakeHost || !strings.HasSuffix(req.URL.Path, ".torrent") {
+		return fakeResponse(req, http.StatusNotFound, "text/plain", []byte("not found")), nil
+	}
+	if err := t.f.call(req.Context()); err != nil {
+		return nil, err
+	}
+	name := req.URL.Query().Get("name")
+	size, err := strconv.ParseInt(req.URL.Query().Get("size"), 10, 64)
+	if name == "" || err != nil || size <= 0 || size > 1<<40 {
+		return fakeResponse(req, http.StatusBadRequest, "text/plain", []byte("missing or invalid name or size")), nil
+	}
+	torrent, _ := fakeTorrent(name, size)
+	resp := fakeResponse(req, http.StatusOK, "application/x-bittorrent", torrent)
+	resp.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".torrent"))
+	return resp, nil
+}
+
+func fakeResponse(req *http.Request, code int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func newFake(cfg config.Indexer) (types.Indexer, error) {
+	settings := FakeConfig{
+		Count: 20,
+		Links: "torrent",
+		Seed:  1,
+	}
+	if err := cfg.DecodeSettings(&settings); err != nil {
+		return nil, err
+	}
+	var errs []error
+	if settings.Count < 0 {
+		errs = append(errs, errors.New("count must not be negative"))
+	}
+	if settings.Latency < 0 {
+		errs = append(errs, errors.New("latency must not be negative"))
+	}
+	if settings.FailureRate < 0 || settings.FailureRate > 1 {
+		errs = append(errs, errors.New("failure_rate must be between 0 and 1"))
+	}
+	if settings.FreeleechRatio < 0 || settings.FreeleechRatio > 1 {
+		errs = append(errs, errors.New("freeleech_ratio must be between 0 and 1"))
+	}
+	switch settings.Links {
+	case "torrent", "magnet", "mixed":
+	default:
+		errs = append(errs, fmt.Errorf("links must be torrent, magnet or mixed, not %q", settings.Links))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, &config.Error{ID: cfg.ID, Err: fmt.Errorf("settings: %w", err)}
+	}
+
+	f := &Fake{
+		ID:             cfg.ID,
+		Title:          cfg.Name,
+		Count:          settings.Count,
+		Latency:        settings.Latency,
+		FailureRate:    settings.FailureRate,
+		FreeleechRatio: settings.FreeleechRatio,
+		Links:          settings.Links,
+		Seed:           settings.Seed,
+	}
+	f.Client = &http.Client{Transport: fakeTransport{f}}
+	return f, nil
+}
@@ -0,0 +1,101 @@
+package indexers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"torrProxy/config"
+	"torrProxy/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fakeConfig returns a fake indexer entry with the given settings YAML.
+func fakeConfig(t *testing.T, settings string) config.Indexer {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(settings), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return config.Indexer{ID: "fake", Type: "fake", Settings: *doc.Content[0]}
+}
+
+func newTestFake(t *testing.T, settings string) *Fake {
+	t.Helper()
+	idx, err := newFake(fakeConfig(t, settings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx.(*Fake)
+}
+
+func TestFakeDeterministic(t *testing.T) {
+	f := newTestFake(t, "count: 7\nfreeleech_ratio: 0.5\nlinks: mixed")
+	a, err := f.Search(context.Background(), "Duna Parte Dois")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newTestFake(t, "count: 7\nfreeleech_ratio: 0.5\nlinks: mixed").Search(context.Background(), "Duna Parte Dois")
+	if len(a) != 7 || !reflect.DeepEqual(a, b) {
+		t.Fatalf("results differ between identical searches:\n%v\n%v", a, b)
+	}
+	for i, r := range a {
+		magnet := strings.HasPrefix(r.TorrentURL, "magnet:")
+		if magnet != (i%2 == 1) {
+			t.Errorf("result %d: TorrentURL %q, want magnet %v", i, r.TorrentURL, i%2 == 1)
+		}
+	}
+
+	page, _ := f.SearchPage(context.Background(), "Duna Parte Dois", types.Page{Offset: 5, Limit: 10})
+	if !reflect.DeepEqual(page, a[5:]) {
+		t.Errorf("SearchPage(5, 10) = %d results, want the last 2", len(page))
+	}
+}
+
+func TestFakeDownload(t *testing.T) {
+	f := newTestFake(t, "count: 1")
+	results, err := f.Search(context.Background(), "ubuntu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxied, err := url.Parse(results[0].TorrentURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := f.Client.Get(proxied.Query().Get("dl_url"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+
+	// the info dict runs from "4:info" to the final "e" of the torrent
+	start := bytes.Index(body, []byte("4:info")) + len("4:info")
+	sum := sha1.Sum(body[start : len(body)-1])
+	if got := hex.EncodeToString(sum[:]); got != results[0].InfoHash {
+		t.Errorf("torrent info hash %s, result says %s", got, results[0].InfoHash)
+	}
+}
+
+func TestFakeFailure(t *testing.T) {
+	f := newTestFake(t, "failure_rate: 1")
+	if _, err := f.Search(context.Background(), "x"); err == nil {
+		t.Error("Search succeeded with failure_rate 1")
+	}
+	if _, err := newFake(config.Indexer{ID: "fake", Type: "fake"}); err != nil {
+		t.Errorf("defaults rejected: %v", err)
+	}
+	if _, err := newFake(fakeConfig(t, "links: ftp\nfailure_rate: 2")); err == nil {
+		t.Error("invalid settings accepted")
+	}
+}
@@ -0,0 +1,155 @@
+package indexers
+
+// Periodic indexer health probes (types.HealthChecker).
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"torrProxy/metrics"
+	"torrProxy/types"
+
+	"go.uber.org/zap"
+)
+
+// healthTimeout bounds one instance's health check.
+const healthTimeout = 20 * time.Second
+
+// Health is the last health check result of an instance.
+type Health struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Status is "ok", "failing", "pending" (not checked yet) or
+	// "unsupported" (the indexer has no HealthCheck).
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	LatencyMS int64     `json:"latency_ms,omitempty"`
+}
+
+// OK reports whether the instance can be expected to serve searches.
+func (h Health) OK() bool {
+	return h.Status == "ok" || h.Status == "unsupported"
+}
+
+// Monitor probes the registry's indexers on a schedule and caches the
+// results, so health endpoints never wait on trackers.
+type Monitor struct {
+	Registry *types.Registry
+	Interval time.Duration
+
+	mu      sync.RWMutex
+	results map[string]Health
+}
+
+// NewMonitor returns a monitor probing reg every interval.
+func NewMonitor(reg *types.Registry, interval time.Duration) *Monitor {
+	return &Monitor{Registry: reg, Interval: interval, results: make(map[string]Health)}
+}
+
+// Run checks every indexer now and then every Interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check probes every registered indexer in parallel and records the results.
+func (m *Monitor) Check(ctx context.Context) {
+	idxs, release := m.Registry.Acquire()
+	defer release()
+
+	var wg sync.WaitGroup
+	for _, idx := range idxs {
+		hc, ok := idx.(types.HealthChecker)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(idx types.Indexer, hc types.HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+			defer cancel()
+			start := time.Now()
+			err := hc.HealthCheck(ctx)
+			h := Health{
+				ID:        idx.Id(),
+				Name:      idx.Name(),
+				Status:    "ok",
+				CheckedAt: time.Now(),
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				h.Status = "failing"
+				h.Error = err.Error()
+			}
+			m.record(h)
+		}(idx, hc)
+	}
+	wg.Wait()
+
+	// forget instances removed by a reload
+	live := make(map[string]bool, len(idxs))
+	for _, idx := range idxs {
+		live[strings.ToLower(idx.Id())] = true
+	}
+	m.mu.Lock()
+	for key := range m.results {
+		if !live[key] {
+			delete(m.results, key)
+		}
+	}
+	m.mu.Unlock()
+}
+
+func (m *Monitor) record(h Health) {
+	key := strings.ToLower(h.ID)
+	m.mu.Lock()
+	prev, seen := m.results[key]
+	m.results[key] = h
+	m.mu.Unlock()
+
+	switch {
+	case h.Status == "failing" && (!seen || prev.Status != "failing"):
+		zap.L().Warn("Indexer health check failed", zap.String("indexer", h.ID), zap.String("error", h.Error))
+	case h.Status == "ok" && seen && prev.Status == "failing":
+		zap.L().Info("Indexer recovered", zap.String("indexer", h.ID))
+	}
+}
+
+// Status returns the cached health of every registered indexer, in
+// registry order. Checkable instances not probed yet count as cache misses
+// in the "health" cache metric.
+func (m *Monitor) Status() []Health {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idxs := m.Registry.All()
+	out := make([]Health, 0, len(idxs))
+	for _, idx := range idxs {
+		h, ok := m.results[strings.ToLower(idx.Id())]
+		switch {
+		case ok:
+			metrics.ObserveCache("health", true)
+		case isHealthChecker(idx):
+			metrics.ObserveCache("health", false)
+			h = Health{ID: idx.Id(), Name: idx.Name(), Status: "pending"}
+		default:
+			h = Health{ID: idx.Id(), Name: idx.Name(), Status: "unsupported"}
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func isHealthChecker(idx types.Indexer) bool {
+	_, ok := idx.(types.HealthChecker)
+	return ok
+}
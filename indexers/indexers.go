@@ -0,0 +1,109 @@
+package indexers
+
+// Construction of indexer instances from the configuration file.
+//
+// Each indexer type registers a Factory under the name used in the `type`
+// key of a config entry. Factories only decode settings; network setup
+// (logins) happens in the instance's Init hook, called by Start and by
+// Manager.Apply (see manager.go).
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"torrProxy/config"
+	"torrProxy/tracing"
+	"torrProxy/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExternalURL is the public base URL of this server, used to build
+// /torrproxy/download links (config server.external_url).
+var ExternalURL = "http://127.0.0.1:8090"
+
+// initTimeout bounds an instance's Init hook (e.g. a tracker login).
+const initTimeout = 30 * time.Second
+
+// Factory builds an indexer instance from its config entry. It must not do
+// network I/O; implement types.Initializer for that.
+type Factory func(cfg config.Indexer) (types.Indexer, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"amigosshare": newAmigosShare,
+		"capybarabr":  newCapybaraBR,
+		"fake":        newFake,
+		"redetorrent": newRedeTorrent,
+	}
+)
+
+// RegisterType makes an indexer type available to config entries. It panics
+// if the name is already taken.
+func RegisterType(name string, f Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("indexers: type registered twice: " + name)
+	}
+	factories[name] = f
+}
+
+// Types returns the registered type names, sorted.
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds one indexer instance from its config entry, without starting it.
+func New(cfg config.Indexer) (types.Indexer, error) {
+	factoriesMu.RLock()
+	f := factories[cfg.Type]
+	factoriesMu.RUnlock()
+	if f == nil {
+		return nil, &config.Error{ID: cfg.ID, Err: fmt.Errorf("unknown type %q", cfg.Type)}
+	}
+	return f(cfg)
+}
+
+// Start builds an instance and runs its Init hook.
+func Start(ctx context.Context, cfg config.Indexer) (types.Indexer, error) {
+	idx, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := initIndexer(ctx, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// initIndexer runs the Init hook of idx, closing it on failure.
+func initIndexer(ctx context.Context, idx types.Indexer) error {
+	in, ok := idx.(types.Initializer)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "indexer.init", trace.WithAttributes(attribute.String("indexer", idx.Id())))
+	err := in.Init(ctx)
+	tracing.End(span, err)
+	if err != nil {
+		if c, ok := idx.(types.Closer); ok {
+			_ = c.Close()
+		}
+		return fmt.Errorf("init: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package indexers
+
+// Applying configuration (at startup and on reloads) to the running
+// indexer instances.
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"torrProxy/config"
+	"torrProxy/types"
+
+	"go.uber.org/zap"
+)
+
+// Manager owns the running indexer instances and brings them in line with
+// the configuration, rebuilding only the instances whose entry changed.
+type Manager struct {
+	Registry *types.Registry
+
+	mu      sync.Mutex // serializes Apply
+	running map[string]instance
+}
+
+type instance struct {
+	cfg config.Indexer
+	idx types.Indexer
+}
+
+// Changes lists, by instance id, what Apply did.
+type Changes struct {
+	Started   []string `json:"started,omitempty"`
+	Restarted []string `json:"restarted,omitempty"`
+	Stopped   []string `json:"stopped,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+}
+
+// NewManager returns a manager with an empty registry.
+func NewManager() *Manager {
+	return &Manager{
+		Registry: types.NewRegistry(),
+		running:  make(map[string]instance),
+	}
+}
+
+// Apply validates cfgs, starts new and changed instances, and atomically
+// swaps them into the registry. On a configuration error nothing changes.
+// An instance that fails to start (e.g. a rejected login) is logged and
+// listed in Failed; if it was already running, the old instance is kept.
+// Replaced and removed instances are closed once their in-flight requests
+// finish.
+func (m *Manager) Apply(ctx context.Context, cfgs []config.Indexer) (Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// build new and changed instances first: no I/O, and nothing to undo
+	// but Close if one of them is misconfigured
+	type step struct {
+		cfg     config.Indexer
+		idx     types.Indexer
+		fresh   bool
+		initErr error
+	}
+	var steps []*step
+	var errs []error
+	for _, cfg := range cfgs {
+		if !cfg.IsEnabled() {
+			continue
+		}
+		if cur, ok := m.running[instanceKey(cfg.ID)]; ok && cur.cfg.Equal(cfg) {
+			steps = append(steps, &step{cfg: cfg, idx: cur.idx})
+			continue
+		}
+		idx, err := New(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		steps = append(steps, &step{cfg: cfg, idx: idx, fresh: true})
+	}
+	if len(errs) > 0 {
+		for _, s := range steps {
+			if c, ok := s.idx.(types.Closer); ok && s.fresh {
+				_ = c.Close()
+			}
+		}
+		return Changes{}, errors.Join(errs...)
+	}
+
+	// log in etc. in parallel so a slow tracker does not delay the others
+	var wg sync.WaitGroup
+	for _, s := range steps {
+		if s.fresh {
+			wg.Add(1)
+			go func(s *step) {
+				defer wg.Done()
+				s.initErr = initIndexer(ctx, s.idx)
+			}(s)
+		}
+	}
+	wg.Wait()
+
+	var ch Changes
+	next := make(map[string]instance, len(steps))
+	list := make([]types.Indexer, 0, len(steps))
+	for _, s := range steps {
+		key := instanceKey(s.cfg.ID)
+		cur, wasRunning := m.running[key]
+		switch {
+		case s.initErr != nil:
+			zap.L().Error("Failed to start indexer", zap.String("indexer", s.cfg.ID), zap.Error(s.initErr))
+			ch.Failed = append(ch.Failed, s.cfg.ID)
+			if !wasRunning {
+				continue
+			}
+			s.cfg, s.idx = cur.cfg, cur.idx
+		case s.fresh && wasRunning:
+			ch.Restarted = append(ch.Restarted, s.cfg.ID)
+		case s.fresh:
+			ch.Started = append(ch.Started, s.cfg.ID)
+		}
+		next[key] = instance{cfg: s.cfg, idx: s.idx}
+		list = append(list, s.idx)
+	}
+	for key, cur := range m.running {
+		if _, ok := next[key]; !ok {
+			ch.Stopped = append(ch.Stopped, cur.cfg.ID)
+		}
+	}
+
+	drain := m.Registry.Swap(list)
+	m.running = next
+	go func() {
+		if err := drain(); err != nil {
+			zap.L().Warn("Failed to close indexer", zap.Error(err))
+		}
+	}()
+	return ch, nil
+}
+
+// Close stops every instance, waiting for in-flight requests.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = make(map[string]instance)
+	return m.Registry.Close()
+}
+
+func instanceKey(id string) string {
+	return strings.ToLower(id)
+}
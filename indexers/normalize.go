@@ -0,0 +1,75 @@
+package indexers
+
+// Query normalization shared by the search pipeline and the indexers.
+//
+// Brazilian trackers index titles with accents ("Pokémon", "Ação") that
+// clients often send without, and vice versa. Queries are folded to plain
+// ASCII letters with punctuation collapsed, and QueryVariants lists alternate
+// spellings to retry with when a tracker returns nothing.
+
+import (
+	"strings"
+	"unicode"
+)
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"Ú", "U", "Ù", "U", "Û", "U", "Ü", "U",
+	"ç", "c", "Ç", "C", "ñ", "n", "Ñ", "N",
+	"ª", "a", "º", "o",
+)
+
+var apostropheReplacer = strings.NewReplacer("'", "", "’", "", "`", "")
+
+// FoldAccents strips Portuguese/Latin diacritics ("Ação" -> "Acao").
+func FoldAccents(s string) string {
+	return accentReplacer.Replace(s)
+}
+
+// NormalizeQuery lowercases and folds accents, drops apostrophes
+// ("Grey's" -> "greys") and turns any other punctuation into single spaces.
+func NormalizeQuery(q string) string {
+	q = strings.ToLower(FoldAccents(q))
+	q = apostropheReplacer.Replace(q)
+	return strings.Join(strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// QueryVariants returns the spellings to try for q, in order: the normalized
+// query, the query as typed (keeps accents for trackers that need them) and,
+// for "Title: Subtitle" style queries, the main title alone. Duplicates are
+// removed.
+func QueryVariants(q string) []string {
+	q = strings.TrimSpace(q)
+	candidates := []string{NormalizeQuery(q), q}
+	if head, _, ok := strings.Cut(q, ":"); ok {
+		candidates = append(candidates, NormalizeQuery(head))
+	}
+
+	var out []string
+	for _, c := range candidates {
+		if c == "" || containsFold(out, c) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
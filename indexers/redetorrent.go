@@ -1,20 +1,19 @@
 package indexers
 
 // Converted & extended from torrent-yml
-// Config from environment:
-//  - REDE_TORRENT_BASE (default: http://192.168.1.179:4949)
-// This indexer calls /indexers/rede_torrent and expects JSON with results array.
+// Configured by a `redetorrent` indexer entry (see RedeTorrentConfig).
 
 import (
 	"context"
 	"fmt"
 	"net/http"
 	neturl "net/url"
-	"os"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"torrProxy/config"
 	"torrProxy/types"
 
 	"github.com/PuerkitoBio/goquery"
@@ -25,16 +24,31 @@ var infoHashRe = coregex.MustCompile(`xt=urn:btih:([a-fA-F0-9]{40})`)
 var magnetDnRe = coregex.MustCompile(`dn=([^&]+)`)
 var seasonRe = coregex.MustCompile(`(?i)(S0)(\d{1,2})$`)
 
+// RedeTorrentConfig is the settings block of a redetorrent indexer.
+type RedeTorrentConfig struct {
+	BaseURL  string `yaml:"base_url" env:"REDE_TORRENT_BASE"`
+	MaxPages int    `yaml:"max_pages" env:"REDE_TORRENT_MAX_PAGES"`
+}
+
 type RedeTorrent struct {
-	BaseURL string
-	Client  *http.Client
+	ID       string
+	Title    string
+	BaseURL  string
+	MaxPages int // cap on listing pages (/page/N/) scraped per search
+	Client   *http.Client
 }
 
 func (r *RedeTorrent) Name() string {
+	if r.Title != "" {
+		return r.Title
+	}
 	return "Rede Torrent"
 }
 
 func (r *RedeTorrent) Id() string {
+	if r.ID != "" {
+		return r.ID
+	}
 	return "redetorrent"
 }
 
@@ -64,43 +78,79 @@ func (r *RedeTorrent) keywordPreprocess(q string) string {
 }
 
 func (r *RedeTorrent) Search(ctx context.Context, query string) ([]types.Result, error) {
+	return r.SearchPage(ctx, query, types.Page{})
+}
+
+// SearchPage walks WordPress's /page/N/ listing pagination, scraping the
+// detail pages of each listing page, until the requested window is covered.
+func (r *RedeTorrent) SearchPage(ctx context.Context, query string, page types.Page) ([]types.Result, error) {
 	url, err := r.buildURL()
 	if err != nil {
 		return nil, err
 	}
-	u, _ := neturl.Parse(url)
+	base, _ := neturl.Parse(url)
 
-	qp := u.Query()
-	qp.Set("s", r.keywordPreprocess(query))
-	u.RawQuery = qp.Encode()
+	return fetchPages(ctx, page, 0, r.MaxPages, func(n int) ([]types.Result, bool, error) {
+		u := *base
+		if n > 0 {
+			u.Path = path.Join(u.Path, "page", strconv.Itoa(n+1)) + "/"
+		}
+		qp := u.Query()
+		qp.Set("s", r.keywordPreprocess(query))
+		u.RawQuery = qp.Encode()
+
+		links, more, err := r.listLinks(ctx, u.String(), r.FormatQuery(query), n)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Enqueue and process links with a queued semaphore
+		return r.processLinksWithQueue(ctx, links), more, nil
+	})
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+// Recent scrapes the posts listed on the homepage, newest first.
+func (r *RedeTorrent) Recent(ctx context.Context) ([]types.Result, error) {
+	links, _, err := r.listLinks(ctx, r.BaseURL, "", 0)
 	if err != nil {
 		return nil, err
 	}
+	return r.processLinksWithQueue(ctx, links), nil
+}
+
+// listLinks returns the detail page links of listing page n (.capa_lista),
+// keeping only titles containing query when it is not empty. more reports
+// whether the pager links to the next page.
+func (r *RedeTorrent) listLinks(ctx context.Context, pageURL, query string, n int) (links []string, more bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
 	req.Header.Set("User-Agent", "torrProxy/1.0")
 
 	resp, err := r.client().Do(req)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("redetorrent: bad response %d", resp.StatusCode)
+		return nil, false, fmt.Errorf("redetorrent: bad response %d", resp.StatusCode)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	query = r.FormatQuery(query)
 	// Extract links from search results (.capa_lista elements)
-	var links []string
-	doc.Find(".capa_lista a").Each(func(i int, s *goquery.Selection) {
-		if title, exists := s.Attr("title"); exists {
-			if !strings.Contains(strings.ToLower(title), query) {
+	anchors := doc.Find(".capa_lista a")
+	if anchors.Length() == 0 {
+		warnIfNoRows(ctx, r.Id(), pageURL, doc)
+	}
+	anchors.Each(func(i int, s *goquery.Selection) {
+		if title, exists := s.Attr("title"); exists && query != "" {
+			if !strings.Contains(NormalizeQuery(title), query) {
 				return
 			}
 		}
@@ -109,40 +159,9 @@ func (r *RedeTorrent) Search(ctx context.Context, query string) ([]types.Result,
 		}
 	})
 
-	// // Now scrape each detail page
-	// resultsCh := make(chan []types.Result)
-	// var wg sync.WaitGroup
-	// semaphore := make(chan struct{}, 5) // limit concurrency
-
-	// seen := make(map[string]struct{})
-	// for _, link := range links {
-	// 	wg.Add(1)
-	// 	go func(link string) {
-	// 		defer wg.Done()
-	// 		semaphore <- struct{}{}
-	// 		defer func() { <-semaphore }()
-	// 		item, err := r.scrapeDetailPage(ctx, link, seen)
-	// 		resultsCh <- item
-	// 		if err != nil {
-	// 			return
-	// 		}
-	// 	}(link)
-	// }
-
-	// go func() {
-	// 	wg.Wait()
-	// 	close(resultsCh)
-	// }()
-
-	// var results []types.Result
-	// for item := range resultsCh {
-	// 	results = append(results, item...)
-	// }
-
-	// Enqueue and process links with a queued semaphore
-	results := r.processLinksWithQueue(ctx, links)
-
-	return results, nil
+	next := fmt.Sprintf("/page/%d/", n+2)
+	more = doc.Find(fmt.Sprintf(`a.next, a.nextpostslink, a[href*="%s"]`, next)).Length() > 0
+	return links, more, nil
 }
 
 func (r *RedeTorrent) scrapeDetailPage(ctx context.Context, url string, seen map[string]struct{}, mu *sync.Mutex) ([]types.Result, error) {
@@ -152,7 +171,7 @@ func (r *RedeTorrent) scrapeDetailPage(ctx context.Context, url string, seen map
 	}
 	req.Header.Set("User-Agent", "torrProxy/1.0")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := r.client().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -183,12 +202,10 @@ func (r *RedeTorrent) scrapeDetailPage(ctx context.Context, url string, seen map
 		for line := range strings.Lines(s.Text()) {
 			line = strings.TrimSpace(line)
 
-			if strings.Contains(line, "Tamanho:") {
-				size := strings.TrimSuffix(strings.TrimPrefix(line, "Tamanho: "), " GB")
-				if size == "Desconhecido" {
-					size = "0"
-				} else {
-					size += " GB"
+			if _, after, ok := strings.Cut(line, "Tamanho:"); ok {
+				size = strings.TrimSpace(after)
+				if strings.EqualFold(size, "Desconhecido") {
+					size = ""
 				}
 				continue
 			}
@@ -207,6 +224,16 @@ func (r *RedeTorrent) scrapeDetailPage(ctx context.Context, url string, seen map
 
 	pub := ParseDateWithFormats(date, []string{time.RFC3339, "2006-01-02T15:04:05.000000Z", "2006-01-02 15:04:05", "02/01/2006 15:04:05"})
 
+	// section: WordPress category links (Filmes, Séries...), else the post slug
+	var section types.Category
+	doc.Find(`a[rel~="category"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		section = categoryFromText(s.Text())
+		return section == 0
+	})
+	if section == 0 {
+		section = categoryFromText(url)
+	}
+
 	// Build results
 	var results []types.Result
 	for _, magnet := range magnets {
@@ -224,14 +251,21 @@ func (r *RedeTorrent) scrapeDetailPage(ctx context.Context, url string, seen map
 			title, _ = neturl.QueryUnescape(matches[1])
 		}
 
+		category := section
+		if category == 0 {
+			category = guessCategory(title)
+		}
+
 		results = append(results, types.Result{
 			Title:       title,
 			Link:        url,
 			Description: originalTitle,
 			InfoHash:    infoHash,
 			Size:        size,
+			SizeBytes:   ParseSize(size),
 			PubDate:     pub,
 			TorrentURL:  magnet,
+			Category:    refineCategory(category, title),
 		})
 	}
 
@@ -249,21 +283,53 @@ func ExtractInfoHash(magnet string) string {
 
 func (r *RedeTorrent) FormatQuery(q string) string {
 	// For TV shows, convert "S01E02" to "S0X02" to match site format
-	q = strings.ToLower(q)
+	q = NormalizeQuery(q)
 	q = strings.ReplaceAll(q, " complet", "")
 	q = seasonRe.ReplaceAllString(q, "")
-	return q
+	return strings.TrimSpace(q)
+}
+
+func newRedeTorrent(cfg config.Indexer) (types.Indexer, error) {
+	settings := RedeTorrentConfig{
+		BaseURL:  "https://redetorrent.com",
+		MaxPages: 3,
+	}
+	if err := cfg.DecodeSettings(&settings); err != nil {
+		return nil, err
+	}
+	return &RedeTorrent{
+		ID:       cfg.ID,
+		Title:    cfg.Name,
+		BaseURL:  settings.BaseURL,
+		MaxPages: settings.MaxPages,
+		Client:   newHTTPClient(cfg.ID, 15*time.Second, nil),
+	}, nil
 }
 
-func init() {
-	base := os.Getenv("REDE_TORRENT_BASE")
-	if base == "" {
-		base = "https://redetorrent.com"
+// HealthCheck verifies the site's homepage answers.
+func (r *RedeTorrent) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.BaseURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "torrProxy/1.0")
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return err
 	}
-	idx := &RedeTorrent{
-		BaseURL: base,
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("redetorrent: bad response %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close drops idle connections.
+func (r *RedeTorrent) Close() error {
+	if r.Client != nil {
+		r.Client.CloseIdleConnections()
 	}
-	types.Indexers = append(types.Indexers, idx)
+	return nil
 }
 
 func (r *RedeTorrent) processLinksWithQueue(ctx context.Context, links []string) []types.Result {
@@ -0,0 +1,31 @@
+package indexers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"torrProxy/internal/httpfixture"
+	"torrProxy/types"
+)
+
+func TestRedeTorrentSearch(t *testing.T) {
+	r := &RedeTorrent{
+		BaseURL:  "https://redetorrent.com",
+		MaxPages: 1,
+		Client:   &http.Client{Transport: httpfixture.Transport(t, "testdata/redetorrent/search.json")},
+	}
+	results, err := r.Search(context.Background(), "Duna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no results")
+	}
+	// detail pages are scraped concurrently
+	slices.SortFunc(results, func(a, b types.Result) int {
+		return strings.Compare(a.InfoHash, b.InfoHash)
+	})
+	httpfixture.Golden(t, "testdata/redetorrent/search.golden.json", results)
+}
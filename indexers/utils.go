@@ -1,12 +1,17 @@
 package indexers
 
 import (
+	"context"
+	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"strconv"
 	"strings"
 	"time"
+	"torrProxy/logging"
+	"torrProxy/metrics"
+	"torrProxy/tracing"
+	"torrProxy/types"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/coregx/coregex"
@@ -19,6 +24,17 @@ import (
 // Helpers reused by indexers
 //
 
+// newHTTPClient returns the HTTP client of indexer id: requests are counted
+// in the upstream metrics, logged at debug level, traced and, when enabled,
+// captured to disk (see logging.SetupCapture). jar may be nil.
+func newHTTPClient(id string, timeout time.Duration, jar http.CookieJar) *http.Client {
+	return &http.Client{
+		Jar:       jar,
+		Timeout:   timeout,
+		Transport: metrics.Transport(id, logging.Transport(id, tracing.Transport(logging.CaptureTransport(id, nil)))),
+	}
+}
+
 // AbsURL resolves href (which may be relative) against base.
 func AbsURL(base, href string) string {
 	if href == "" {
@@ -76,8 +92,110 @@ func ParseDateWithFormats(s string, layouts []string) time.Time {
 	return time.Time{}
 }
 
+var sizeRe = coregex.MustCompile(`(?i)^([\d.,]+)\s*([kmgtp]?i?b|bytes?)?$`)
+
+// sizeUnits maps lowercase unit suffixes to their multiplier. Trackers label
+// binary sizes with SI suffixes ("4.3 GB" means GiB), so both spellings are
+// treated as powers of 1024, the same convention Jackett uses.
+var sizeUnits = map[string]float64{
+	"":      1,
+	"b":     1,
+	"byte":  1,
+	"bytes": 1,
+	"kb":    1 << 10,
+	"kib":   1 << 10,
+	"mb":    1 << 20,
+	"mib":   1 << 20,
+	"gb":    1 << 30,
+	"gib":   1 << 30,
+	"tb":    1 << 40,
+	"tib":   1 << 40,
+	"pb":    1 << 50,
+	"pib":   1 << 50,
+}
+
+// ParseSize converts a human size ("4,3 GB", "700 MiB", "1.234,5 MB" or raw
+// bytes) into bytes. Unknown sizes ("Desconhecido", "") return 0.
+func ParseSize(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "Desconhecido") {
+		return 0
+	}
+	m := sizeRe.FindStringSubmatch(s)
+	if len(m) != 3 {
+		return 0
+	}
+	mult, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseFloat(normalizeDecimal(m[1]), 64)
+	if err != nil {
+		return 0
+	}
+	return int64(n * mult)
+}
+
+// normalizeDecimal rewrites pt-BR ("1.234,5") and en ("1,234.5") numbers into
+// the form strconv expects. When both separators appear the last one is the
+// decimal point, repeated separators are thousands and a lone comma is
+// decimal (pt-BR trackers).
+func normalizeDecimal(num string) string {
+	comma := strings.LastIndex(num, ",")
+	dot := strings.LastIndex(num, ".")
+	switch {
+	case comma >= 0 && dot >= 0 && comma > dot:
+		num = strings.ReplaceAll(num, ".", "")
+		return strings.Replace(num, ",", ".", 1)
+	case comma >= 0 && dot >= 0:
+		return strings.ReplaceAll(num, ",", "")
+	case strings.Count(num, ",") > 1:
+		return strings.ReplaceAll(num, ",", "")
+	case comma >= 0:
+		return strings.Replace(num, ",", ".", 1)
+	case strings.Count(num, ".") > 1:
+		return strings.ReplaceAll(num, ".", "")
+	}
+	return num
+}
+
+// fetchPages calls fetch for consecutive tracker pages (0-based) until the
+// window [page.Offset, page.Offset+page.Limit) is covered, the tracker reports
+// no more pages or maxPages pages were read. perPage is the tracker page size,
+// or 0 when unknown (pages are then read from the start and Offset skipped).
+// A failing page after the first ends the walk with what was collected.
+func fetchPages(ctx context.Context, page types.Page, perPage, maxPages int, fetch func(n int) ([]types.Result, bool, error)) ([]types.Result, error) {
+	first, skip := 0, page.Offset
+	if perPage > 0 {
+		first, skip = page.Offset/perPage, page.Offset%perPage
+	}
+	var out []types.Result
+	for n := first; n < first+max(maxPages, 1); n++ {
+		results, more, err := fetch(n)
+		if err != nil {
+			if n == first {
+				return nil, err
+			}
+			logging.L(ctx).Warn("Stopping pagination after page error", zap.Int("page", n), zap.Error(err))
+			break
+		}
+		out = append(out, results...)
+		if !more || page.Limit <= 0 || len(out) >= skip+page.Limit {
+			break
+		}
+	}
+	if skip >= len(out) {
+		return nil, nil
+	}
+	out = out[skip:]
+	if page.Limit > 0 && len(out) > page.Limit {
+		out = out[:page.Limit]
+	}
+	return out, nil
+}
+
 func buildTorrProxyDownloadLink(indexerID, dlURL string) string {
-	u, err := url.Parse(defaultEnv("EXTERNAL_URL", "http://127.0.0.1:8090"))
+	u, err := url.Parse(ExternalURL)
 	if err != nil {
 		zap.L().Error("Error on Parse Neturl", zap.Error(err))
 		return ""
@@ -145,9 +263,16 @@ func toInt(v interface{}) int {
 	return 0
 }
 
-func defaultEnv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// noResultsRe matches the "nothing found" messages of the supported sites.
+var noResultsRe = coregex.MustCompile(`(?i)nenhum (?:resultado|torrent|registro|post)|n[ãa]o (?:foi|foram) encontrad|nada encontrado|no results|nothing found|not found`)
+
+// warnIfNoRows logs a warning for a page that parsed but yielded no rows
+// without saying it found nothing: the site's markup has likely changed and
+// the selectors need fixing (enable debug.capture to keep the raw page).
+func warnIfNoRows(ctx context.Context, indexer, pageURL string, doc *goquery.Document) {
+	if noResultsRe.MatchString(doc.Text()) {
+		return
 	}
-	return def
+	logging.L(ctx).Warn("Page has no result rows and no \"no results\" message; selectors may be broken",
+		zap.String("indexer", indexer), zap.String("url", pageURL))
 }
@@ -0,0 +1,268 @@
+// Package httpfixture records tracker HTTP exchanges to fixture files once
+// and replays them in tests, so indexers can be tested without hitting live
+// trackers.
+//
+// Tests get a transport for a fixture with Transport. By default it replays
+// the fixture from an httptest server; run the tests with -record (and the
+// credentials the test asks for) to hit the real site and rewrite it.
+// Recorded fixtures are redacted with logging.Redact and keep only the
+// response headers indexers look at.
+package httpfixture
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"torrProxy/config"
+	"torrProxy/logging"
+
+	"github.com/goccy/go-json"
+)
+
+var (
+	record = flag.Bool("record", false, "record fixtures from the live trackers instead of replaying them")
+	update = flag.Bool("update", false, "rewrite golden files with the current results")
+)
+
+// Recording reports whether the tests run with -record, i.e. against the
+// real sites.
+func Recording() bool {
+	return *record
+}
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// Fixture is the sequence of exchanges of one test scenario.
+type Fixture struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Load reads the fixture at path.
+func Load(path string) (*Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture to path, creating its directory.
+func (f *Fixture) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, marshal(f), 0o644)
+}
+
+// Transport returns the transport an indexer under test should use for the
+// fixture at path: a replay of it or, with -record, a Recorder against the
+// real site whose exchanges are saved to path when the test ends.
+func Transport(t testing.TB, path string) http.RoundTripper {
+	t.Helper()
+	if !*record {
+		return Replay(t, path)
+	}
+	r := &Recorder{}
+	t.Cleanup(func() {
+		if err := r.Fixture().Save(path); err != nil {
+			t.Errorf("httpfixture: saving %s: %v", path, err)
+		}
+	})
+	return r
+}
+
+// keptHeaders are the response headers saved by Recorder; the rest (dates,
+// server banners...) only make fixtures noisy.
+var keptHeaders = []string{"Content-Type", "Location", "Refresh", "Set-Cookie"}
+
+// Recorder is a transport that performs requests with Base
+// (http.DefaultTransport if nil) and keeps a redacted copy of each exchange.
+type Recorder struct {
+	Base http.RoundTripper
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	ex := Exchange{
+		Method:      req.Method,
+		URL:         logging.Redact(req.URL.String()),
+		RequestBody: logging.Redact(string(reqBody)),
+		Status:      resp.StatusCode,
+		Header:      http.Header{},
+		Body:        logging.Redact(string(body)),
+	}
+	for _, k := range keptHeaders {
+		for _, v := range resp.Header.Values(k) {
+			if k == "Set-Cookie" {
+				// keep the cookie's name and attributes, not its value
+				name, rest, _ := strings.Cut(v, "=")
+				_, attrs, _ := strings.Cut(rest, ";")
+				v = name + "=" + config.Redacted
+				if attrs != "" {
+					v += ";" + attrs
+				}
+			}
+			ex.Header.Add(k, logging.Redact(v))
+		}
+	}
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, ex)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Fixture returns the exchanges recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{Exchanges: append([]Exchange(nil), r.exchanges...)}
+}
+
+// urlHeader carries the original request URL from the replay transport to
+// the replay server.
+const urlHeader = "X-Httpfixture-Url"
+
+// Replay serves the fixture at path from an httptest server and returns a
+// transport sending every request there, whatever its host, so absolute
+// links found in recorded pages replay too. Requests are matched on method
+// and (redacted) URL; repeated requests get the recorded responses in order,
+// the last one repeating. An unrecorded request fails the test.
+func Replay(t testing.TB, path string) http.RoundTripper {
+	t.Helper()
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("httpfixture: %v (run with -record to create it)", err)
+	}
+
+	var mu sync.Mutex
+	queues := make(map[string][]Exchange)
+	for _, ex := range f.Exchanges {
+		key := ex.Method + " " + ex.URL
+		queues[key] = append(queues[key], ex)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Method + " " + logging.Redact(req.Header.Get(urlHeader))
+		mu.Lock()
+		q := queues[key]
+		if len(q) == 0 {
+			mu.Unlock()
+			t.Errorf("httpfixture: no recorded response for %s in %s", key, path)
+			http.Error(w, "no recorded response", http.StatusNotFound)
+			return
+		}
+		ex := q[0]
+		if len(q) > 1 {
+			queues[key] = q[1:]
+		}
+		mu.Unlock()
+
+		for k, vs := range ex.Header {
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+		w.WriteHeader(ex.Status)
+		_, _ = io.WriteString(w, ex.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		out := req.Clone(req.Context())
+		out.Header.Set(urlHeader, req.URL.String())
+		out.URL.Scheme = "http"
+		out.URL.Host = srv.Listener.Addr().String()
+		out.Host = ""
+		resp, err := srv.Client().Transport.RoundTrip(out)
+		if resp != nil {
+			// cookies and redirects resolve against the URL the indexer asked for
+			resp.Request = req
+		}
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Golden compares got, rendered as indented JSON, with the golden file at
+// path. Run with -update to (re)write it.
+func Golden(t testing.TB, path string, got any) {
+	t.Helper()
+	b := marshal(got)
+	if *update || *record {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("httpfixture: %v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("results differ from %s (run with -update if the change is intended)\n--- got\n%s\n--- want\n%s", path, b, want)
+	}
+}
+
+func marshal(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"torrProxy/config"
+
+	"go.uber.org/zap"
+)
+
+// maxCaptureBody caps the bytes of one body saved by the capture.
+const maxCaptureBody = 2 << 20
+
+type captureState struct {
+	dir  string
+	keep int
+	seq  atomic.Uint64
+	mu   sync.Mutex // serializes writes and rotation
+}
+
+var capture atomic.Pointer[captureState]
+
+// SetupCapture turns the capture of raw tracker exchanges on or off; it may be
+// called again on reload. When on, every request made through
+// CaptureTransport is saved, redacted, as one file in cfg.CaptureDir,
+// keeping the newest cfg.CaptureKeep files.
+func SetupCapture(cfg config.Debug) error {
+	if !cfg.Capture {
+		if capture.Swap(nil) != nil {
+			zap.L().Info("Stopped capturing raw tracker responses")
+		}
+		return nil
+	}
+	if c := capture.Load(); c != nil && c.dir == cfg.CaptureDir && c.keep == cfg.CaptureKeep {
+		return nil
+	}
+	if err := os.MkdirAll(cfg.CaptureDir, 0o700); err != nil {
+		return err
+	}
+	capture.Store(&captureState{dir: cfg.CaptureDir, keep: cfg.CaptureKeep})
+	zap.L().Warn("Capturing raw tracker responses", zap.String("dir", cfg.CaptureDir), zap.Int("keep", cfg.CaptureKeep))
+	return nil
+}
+
+// CaptureTransport wraps base (http.DefaultTransport if nil) so that, while
+// capture is on, each exchange of indexer is saved (see SetupCapture).
+func CaptureTransport(indexer string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &captureTransport{indexer: indexer, base: base}
+}
+
+type captureTransport struct {
+	indexer string
+	base    http.RoundTripper
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := capture.Load()
+	if c == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(body, maxCaptureBody))
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	var respBody []byte
+	if err == nil {
+		// read what we save, then hand the caller the full body back
+		respBody, _ = io.ReadAll(io.LimitReader(resp.Body, maxCaptureBody))
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(respBody), resp.Body), resp.Body}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# indexer=%s request_id=%s at=%s duration=%s\n",
+		t.indexer, RequestID(req.Context()), start.Format(time.RFC3339Nano), time.Since(start))
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, req.URL)
+	writeHeaders(&b, "> ", req.Header)
+	if len(reqBody) > 0 {
+		fmt.Fprintf(&b, ">\n%s\n", reqBody)
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "\n< error: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "\n< %s %s\n", resp.Proto, resp.Status)
+		writeHeaders(&b, "< ", resp.Header)
+		fmt.Fprintf(&b, "<\n%s\n", respBody)
+	}
+	if werr := c.write(t.indexer, Redact(b.String())); werr != nil {
+		zap.L().Warn("Failed to save captured response", zap.Error(werr))
+	}
+	return resp, err
+}
+
+func writeHeaders(b *strings.Builder, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		switch strings.ToLower(k) {
+		case "authorization", "cookie", "set-cookie":
+			v = config.Redacted
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+	}
+}
+
+// write saves one exchange and deletes the oldest files beyond keep. File
+// names sort chronologically.
+func (c *captureState) write(indexer, dump string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := fmt.Sprintf("%s-%06d-%s.txt", time.Now().UTC().Format("20060102T150405.000"), c.seq.Add(1), indexer)
+	if err := os.WriteFile(filepath.Join(c.dir, name), []byte(dump), 0o600); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".txt") {
+			files = append(files, e.Name())
+		}
+	}
+	for len(files) > c.keep {
+		_ = os.Remove(filepath.Join(c.dir, files[0]))
+		files = files[1:]
+	}
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
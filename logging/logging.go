@@ -0,0 +1,111 @@
+// Package logging configures the global zap logger and carries a request
+// ID through contexts so every log line of one API request can be found.
+// Log output is scrubbed of credentials (see Redact).
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"torrProxy/config"
+
+	"github.com/coregx/coregex"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Setup replaces the global logger according to cfg.
+func Setup(cfg config.Log) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(strings.ToLower(cfg.Level))
+	if err != nil {
+		return nil, err
+	}
+	zc := zap.NewProductionConfig()
+	if cfg.Encoding == "console" {
+		zc.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+	zc.Encoding = cfg.Encoding
+	zc.Level = zap.NewAtomicLevelAt(level)
+	zc.Sampling = nil
+	zc.OutputPaths = []string{cfg.Output}
+
+	logger, err := zc.Build(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return redactCore{c}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("log.output: %w", err)
+	}
+	zap.ReplaceGlobals(logger)
+	return logger, nil
+}
+
+// secrets match credentials in URLs, headers and error strings.
+var secrets = []struct {
+	re   *coregex.Regexp
+	repl string
+}{
+	// query or form parameters: tracker passkeys, API tokens, passwords and whole proxied download URLs
+	{coregex.MustCompile(`(?i)((?:^|[?&\n])(?:api_?token|api_?key|passkey|torrent_pass|authkey|rsskey|token|key|password|pass|dl_url)=)[^&\s"']+`), "$1" + config.Redacted},
+	// Authorization: Bearer ... / Basic ...
+	{coregex.MustCompile(`(?i)((?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]+`), "$1" + config.Redacted},
+	// Cookie / Set-Cookie headers
+	{coregex.MustCompile(`(?i)((?:set-)?cookie:\s*)[^\r\n"]+`), "$1" + config.Redacted},
+	// user:password@ in URLs
+	{coregex.MustCompile(`(://[^:/@\s"]+:)[^@\s"]+@`), "$1" + config.Redacted + "@"},
+}
+
+// Redact replaces credentials found in s with config.Redacted.
+func Redact(s string) string {
+	for _, sec := range secrets {
+		s = sec.re.ReplaceAllString(s, sec.repl)
+	}
+	return s
+}
+
+// redactCore scrubs messages and string/error fields before encoding.
+// Fields named like secrets (see config.IsSecret) are hidden entirely.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = Redact(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for n, f := range fields {
+		switch {
+		case config.IsSecret(f.Key):
+			out[n] = zap.String(f.Key, config.Redacted)
+		case f.Type == zapcore.StringType:
+			out[n] = zap.String(f.Key, Redact(f.String))
+		case f.Type == zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				out[n] = zap.String(f.Key, Redact(err.Error()))
+			} else {
+				out[n] = f
+			}
+		case f.Type == zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok {
+				out[n] = zap.String(f.Key, Redact(s.String()))
+			} else {
+				out[n] = f
+			}
+		default:
+			out[n] = f
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/coregx/coregex"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the request ID in API requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// validRequestID limits IDs accepted from clients to something safe to log.
+var validRequestID = coregex.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// WithRequestID returns ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// L returns the global logger, tagged with the request ID of ctx if any.
+func L(ctx context.Context) *zap.Logger {
+	if id := RequestID(ctx); id != "" {
+		return zap.L().With(zap.String("request_id", id))
+	}
+	return zap.L()
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware gives every request a request ID (the client's X-Request-ID
+// when valid), echoes it in the response and logs the request once served.
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(WithRequestID(r.Context(), id))
+
+		start := time.Now()
+		rec := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		L(r.Context()).Info("Request",
+			zap.String("method", r.Method),
+			zap.String("uri", r.URL.RequestURI()),
+			zap.Int("status", rec.code()),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) code() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Transport wraps base (http.DefaultTransport if nil) with a debug log line
+// per outbound request of indexer, tagged with the request ID of the
+// request's context. The ID is not sent to trackers.
+func Transport(indexer string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{indexer: indexer, base: base}
+}
+
+type transport struct {
+	indexer string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if ce := zap.L().Check(zap.DebugLevel, "Upstream request"); ce != nil {
+		fields := []zap.Field{
+			zap.String("indexer", t.indexer),
+			zap.String("method", req.Method),
+			zap.String("url", req.URL.String()),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.Int("status", resp.StatusCode))
+		}
+		if id := RequestID(req.Context()); id != "" {
+			fields = append(fields, zap.String("request_id", id))
+		}
+		ce.Write(fields...)
+	}
+	return resp, err
+}
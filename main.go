@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"torrProxy/api"
-	"torrProxy/types"
+	"torrProxy/clients"
+	"torrProxy/config"
+	"torrProxy/indexers"
+	"torrProxy/logging"
+	"torrProxy/metrics"
+	"torrProxy/search"
+	"torrProxy/tracing"
 
 	_ "github.com/joho/godotenv/autoload"
 	_ "golang.org/x/crypto/x509roots/fallback"
@@ -21,127 +27,138 @@ func init() {
 	// Force pure Go DNS resolver (no CGO)
 	net.DefaultResolver.PreferGo = true
 	net.DefaultResolver.Dial = nil // Use default dialer
-	// Global logger
+	// Bootstrap logger, until the config's log section is applied
 	zapConfig := zap.NewDevelopmentConfig()
-	encoder := zap.NewDevelopmentEncoderConfig()
-	//zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	zapConfig.EncoderConfig = encoder
+	zapConfig.EncoderConfig = zap.NewDevelopmentEncoderConfig()
 	zapConfig.Encoding = "console"
 	zap.ReplaceGlobals(zap.Must(zapConfig.Build()))
 }
 
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/search", searchHandler)
-
-	api.RegisterTorrProxyDownload(mux)
+	os.Exit(run(os.Args[1:]))
+}
 
-	addr := ":8090"
-	srv := &http.Server{
-		Addr:         addr,
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+// serve runs the HTTP server until SIGINT/SIGTERM or a listener failure and
+// returns the exit status.
+func serve(cfgPath string) int {
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		zap.L().Fatal("Invalid configuration", zap.String("path", cfgPath), zap.Error(err))
 	}
+	logger, err := logging.Setup(cfg.Log)
+	if err != nil {
+		zap.L().Fatal("Invalid log configuration", zap.Error(err))
+	}
+	defer logger.Sync()
+	indexers.ExternalURL = cfg.Server.ExternalURL
 
-	zap.L().Info(fmt.Sprintf("Listening on %s", addr), zap.Strings("indexers", listIndexerIds()))
-	zap.L().Fatal("FATAL!!", zap.Error(srv.ListenAndServe()))
-}
-
-func listIndexerIds() []string {
-	ids := make([]string, 0, len(types.Indexers))
-	for _, idx := range types.Indexers {
-		ids = append(ids, idx.Id())
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		zap.L().Fatal("Failed to set up tracing", zap.Error(err))
 	}
-	return ids
-}
+	if err := logging.SetupCapture(cfg.Debug); err != nil {
+		zap.L().Fatal("Failed to set up debug capture", zap.Error(err))
+	}
+	manager := indexers.NewManager()
+	if _, err := manager.Apply(context.Background(), cfg.Indexers); err != nil {
+		zap.L().Fatal("Invalid indexer configuration", zap.String("path", cfgPath), zap.Error(err))
+	}
+	registry := manager.Registry
+	monitor := indexers.NewMonitor(registry, cfg.Server.HealthInterval)
+	go monitor.Run(context.Background())
 
-// /search?q=ubuntu&indexers=Nyaa (rss),Mock
-// If indexers param is omitted, search all indexers.
-func searchHandler(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query().Get("q")
-	if q == "" {
-		http.Error(w, "missing q parameter", http.StatusBadRequest)
-		return
+	downloadClients := clients.NewSet()
+	if err := downloadClients.Apply(cfg.Clients); err != nil {
+		zap.L().Fatal("Invalid client configuration", zap.String("path", cfgPath), zap.Error(err))
 	}
-	indexerParam := r.URL.Query().Get("indexers")
-	var toSearch []types.Indexer
-
-	if indexerParam == "" {
-		toSearch = types.Indexers
-	} else {
-		// comma-separated names
-		requested := map[string]bool{}
-		for _, nm := range strings.Split(indexerParam, ",") {
-			requested[strings.TrimSpace(nm)] = true
+
+	// reload re-reads the config file (SIGHUP or POST /admin/reload). Only
+	// indexer, client and debug changes apply without a restart.
+	reload := func(ctx context.Context) (indexers.Changes, error) {
+		next, err := config.Load(cfgPath)
+		if err != nil {
+			return indexers.Changes{}, err
+		}
+		if next.Server != cfg.Server || next.Log != cfg.Log || next.Tracing != cfg.Tracing {
+			zap.L().Warn("Server, log or tracing settings changed; restart to apply them", zap.String("path", cfgPath))
 		}
-		for _, idx := range types.Indexers {
-			if requested[idx.Id()] {
-				toSearch = append(toSearch, idx)
-			}
+		if err := logging.SetupCapture(next.Debug); err != nil {
+			return indexers.Changes{}, err
 		}
-		if len(toSearch) == 0 {
-			http.Error(w, "no matching indexers found", http.StatusBadRequest)
-			return
+		if err := downloadClients.Apply(next.Clients); err != nil {
+			return indexers.Changes{}, err
 		}
+		changes, err := manager.Apply(ctx, next.Indexers)
+		if err != nil {
+			return changes, err
+		}
+		go monitor.Check(context.Background())
+		zap.L().Info("Configuration reloaded", zap.Any("changes", changes), zap.Strings("indexers", registry.IDs()))
+		return changes, nil
 	}
+	go reloadOnSIGHUP(reload)
 
-	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
-	defer cancel()
+	// aliases maps titles/IDs to their alternate names; see search/alias.go.
+	aliases := search.NewAliases(cfg.Server.AliasesFile)
 
-	type backendResp struct {
-		Indexer string
-		Results []types.Result
-		Error   string
-	}
-	ch := make(chan backendResp, len(toSearch))
-
-	// query backends in parallel
-	for _, idx := range toSearch {
-		go func(idx types.Indexer) {
-			results, err := idx.Search(ctx, q)
-			br := backendResp{Indexer: idx.Name(), Results: results}
-			if err != nil {
-				br.Error = err.Error()
-			}
-			select {
-			case ch <- br:
-			case <-ctx.Done():
-				// context done; try to send a failure record
-				select {
-				case ch <- backendResp{Indexer: idx.Name(), Error: ctx.Err().Error()}:
-				default:
-				}
-			}
-		}(idx)
+	mux := http.NewServeMux()
+	api.RegisterSearch(mux, registry, aliases)
+	api.RegisterTorznab(mux, registry, aliases)
+
+	api.RegisterTorrProxyDownload(mux, registry)
+	api.RegisterClients(mux, registry, downloadClients)
+	api.RegisterHealth(mux, monitor)
+	mux.Handle("/metrics", metrics.Handler())
+	api.RegisterUI(mux)
+	api.RegisterAdmin(mux, &api.Admin{
+		Token:    cfg.Server.AdminToken,
+		File:     config.NewFile(cfgPath),
+		Registry: registry,
+		Reload:   reload,
+	})
+
+	srv := &http.Server{
+		Handler:           tracing.Handler(logging.Middleware(mux)),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ConnContext:       api.ConnContext,
 	}
 
-	// collect and flatten
-	type FlatResult struct {
-		types.Result
-		Source string `json:"source,omitempty"`
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	zap.L().Info("Starting", zap.Strings("listen", cfg.Server.Addresses()), zap.Strings("indexers", registry.IDs()))
+	err = listenAndServe(ctx, srv, cfg.Server)
+	if cerr := manager.Close(); cerr != nil {
+		zap.L().Warn("Failed to close indexers", zap.Error(cerr))
 	}
-	flat := make([]FlatResult, 0)
-	var errs []string
-	for i := 0; i < len(toSearch); i++ {
-		br := <-ch
-		if br.Error != "" {
-			errs = append(errs, br.Indexer+": "+br.Error)
-			continue
-		}
-		for _, r := range br.Results {
-			flat = append(flat, FlatResult{Result: r, Source: br.Indexer})
-		}
+	if terr := shutdownTracing(context.Background()); terr != nil {
+		zap.L().Warn("Failed to flush traces", zap.Error(terr))
+	}
+	if err != nil {
+		zap.L().Error("Server failed", zap.Error(err))
+		return exitFailure
 	}
+	zap.L().Info("Stopped")
+	return exitOK
+}
 
-	// If everything failed, return an error
-	if len(flat) == 0 && len(errs) > 0 {
-		http.Error(w, "all backends failed: "+strings.Join(errs, " | "), http.StatusBadGateway)
-		return
+func reloadOnSIGHUP(reload api.ReloadFunc) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if _, err := reload(ctx); err != nil {
+			zap.L().Error("Config reload failed", zap.Error(err))
+		}
+		cancel()
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(flat)
+func defaultEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
@@ -0,0 +1,242 @@
+// Package metrics defines torrProxy's Prometheus metrics, served at /metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "torrproxy"
+
+var (
+	// incoming API requests, by handler (search, recent, torznab, download)
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "API requests served, by handler and status code.",
+	}, []string{"handler", "code"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "API request latency, by handler.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 15, 30, 60},
+	}, []string{"handler"})
+	inFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "API requests being served, by handler.",
+	}, []string{"handler"})
+
+	// indexer searches (one per indexer per query variant fan-out)
+	searches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "indexer_searches_total",
+		Help:      "Searches sent to an indexer, by outcome (ok, error).",
+	}, []string{"indexer", "outcome"})
+	searchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "indexer_search_duration_seconds",
+		Help:      "Time an indexer took to answer a search.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 15, 30},
+	}, []string{"indexer"})
+	searchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "indexer_errors_total",
+		Help:      "Failed indexer searches, by error type (timeout, canceled, network, other).",
+	}, []string{"indexer", "type"})
+	results = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "indexer_results_total",
+		Help:      "Results returned by an indexer.",
+	}, []string{"indexer"})
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "indexer_logins_total",
+		Help:      "Tracker login attempts, by outcome (ok, error).",
+	}, []string{"indexer", "outcome"})
+
+	// outbound HTTP calls made by indexers
+	upstream = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "HTTP requests made to trackers, by indexer and status code (0 = transport error).",
+	}, []string{"indexer", "code"})
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of HTTP requests made to trackers, until response headers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"indexer"})
+
+	// torrent downloads proxied by /torrproxy/download
+	downloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "download_requests_total",
+		Help:      "Proxied torrent downloads, by indexer and status code returned to the client.",
+	}, []string{"indexer", "code"})
+	downloadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "download_bytes_total",
+		Help:      "Bytes of torrent files proxied to clients.",
+	}, []string{"indexer"})
+
+	cache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, by cache and result (hit, miss).",
+	}, []string{"cache", "result"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Instrument counts and times the requests served by h under handler.
+func Instrument(handler string, h http.HandlerFunc) http.HandlerFunc {
+	gauge := inFlight.WithLabelValues(handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		gauge.Inc()
+		defer gauge.Dec()
+		start := time.Now()
+		rec := &StatusRecorder{ResponseWriter: w}
+		h(rec, r)
+		requestDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+		requests.WithLabelValues(handler, strconv.Itoa(rec.Code())).Inc()
+	}
+}
+
+// ObserveSearch records one indexer search.
+func ObserveSearch(indexer string, elapsed time.Duration, n int, err error) {
+	searchDuration.WithLabelValues(indexer).Observe(elapsed.Seconds())
+	if err != nil {
+		searches.WithLabelValues(indexer, "error").Inc()
+		searchErrors.WithLabelValues(indexer, ErrorType(err)).Inc()
+		return
+	}
+	searches.WithLabelValues(indexer, "ok").Inc()
+	results.WithLabelValues(indexer).Add(float64(n))
+}
+
+// ObserveLogin records a tracker login attempt.
+func ObserveLogin(indexer string, err error) {
+	logins.WithLabelValues(indexer, outcome(err)).Inc()
+}
+
+// ObserveDownload records a proxied download; bytes count only when it
+// succeeded (error bodies are not torrents).
+func ObserveDownload(indexer string, code int, bytes int64) {
+	downloads.WithLabelValues(indexer, strconv.Itoa(code)).Inc()
+	if code < 300 {
+		downloadBytes.WithLabelValues(indexer).Add(float64(bytes))
+	}
+}
+
+// ObserveCache records a lookup in the named cache.
+func ObserveCache(name string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cache.WithLabelValues(name, result).Inc()
+}
+
+// ErrorType classifies err for the error counters.
+func ErrorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "other"
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Transport wraps base (http.DefaultTransport if nil) so every request is
+// counted and timed under indexer.
+func Transport(indexer string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{indexer: indexer, base: base}
+}
+
+type transport struct {
+	indexer string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	upstreamDuration.WithLabelValues(t.indexer).Observe(time.Since(start).Seconds())
+	code := 0
+	if err == nil {
+		code = resp.StatusCode
+	}
+	upstream.WithLabelValues(t.indexer, strconv.Itoa(code)).Inc()
+	return resp, err
+}
+
+// StatusRecorder is a ResponseWriter remembering the status code and the
+// number of body bytes written.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *StatusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *StatusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Code returns the status code sent (200 if none was set explicitly).
+func (s *StatusRecorder) Code() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Bytes returns the number of body bytes written.
+func (s *StatusRecorder) Bytes() int64 {
+	return s.bytes
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *StatusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
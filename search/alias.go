@@ -0,0 +1,150 @@
+package search
+
+// Alternate-title search using a local alias file.
+//
+// Trackers index a release under whichever language the uploader picked, so
+// "Cidade de Deus" and "City of God" find different torrents. The alias file
+// maps IDs (tt0317248, tmdb:598, ...) and titles to every known name, and
+// Expand turns one query into one query per alias:
+//
+//	[
+//	  {"ids": ["tt0317248", "tmdb:598"], "pt": "Cidade de Deus",
+//	   "en": "City of God", "original": "Cidade de Deus", "aka": []}
+//	]
+//
+// The file is user-editable and reloaded when its modification time changes.
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"torrProxy/indexers"
+
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+// Alias is one entry of the alias file.
+type Alias struct {
+	IDs      []string `json:"ids,omitempty"`
+	PT       string   `json:"pt,omitempty"`
+	EN       string   `json:"en,omitempty"`
+	Original string   `json:"original,omitempty"`
+	AKA      []string `json:"aka,omitempty"`
+}
+
+// Titles returns every distinct title of the alias, pt-BR first.
+func (a Alias) Titles() []string {
+	var out []string
+	for _, t := range append([]string{a.PT, a.EN, a.Original}, a.AKA...) {
+		if t = strings.TrimSpace(t); t != "" && !containsNormalized(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Aliases is a lazily (re)loaded alias file. The zero path disables aliases.
+type Aliases struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	entries []Alias
+}
+
+// NewAliases returns an alias set backed by the JSON file at path. A missing
+// file is not an error: it simply yields no aliases until it is created.
+func NewAliases(path string) *Aliases {
+	return &Aliases{path: path}
+}
+
+// Expand returns the queries to run for query: query itself followed by the
+// alias titles of the entry it names. A query that is an alias ID is replaced
+// by the titles; a query starting with an alias title keeps its suffix
+// ("cidade de deus 2002" -> "city of god 2002").
+func (a *Aliases) Expand(query string) []string {
+	out := []string{query}
+	if a == nil {
+		return out
+	}
+	entries := a.load()
+	norm := indexers.NormalizeQuery(query)
+
+	for _, e := range entries {
+		for _, id := range e.IDs {
+			if strings.EqualFold(strings.TrimSpace(id), strings.TrimSpace(query)) {
+				return e.Titles()
+			}
+		}
+	}
+
+	var best Alias
+	bestLen := 0
+	suffix := ""
+	for _, e := range entries {
+		for _, t := range e.Titles() {
+			nt := indexers.NormalizeQuery(t)
+			if nt == "" || len(nt) <= bestLen {
+				continue
+			}
+			if norm == nt || strings.HasPrefix(norm, nt+" ") {
+				best, bestLen, suffix = e, len(nt), strings.TrimPrefix(norm, nt)
+			}
+		}
+	}
+	if bestLen == 0 {
+		return out
+	}
+	for _, t := range best.Titles() {
+		if q := t + suffix; !containsNormalized(out, q) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// load returns the current entries, re-reading the file if it changed.
+func (a *Aliases) load() []Alias {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.path == "" {
+		return nil
+	}
+	st, err := os.Stat(a.path)
+	if err != nil {
+		a.entries, a.modTime = nil, time.Time{}
+		return nil
+	}
+	if st.ModTime().Equal(a.modTime) {
+		return a.entries
+	}
+	b, err := os.ReadFile(a.path)
+	if err != nil {
+		zap.L().Warn("Failed to read alias file", zap.String("path", a.path), zap.Error(err))
+		return a.entries
+	}
+	var entries []Alias
+	if err := json.Unmarshal(b, &entries); err != nil {
+		// remembered so the broken file is not re-read (and warned about)
+		// until it changes again
+		a.modTime = st.ModTime()
+		zap.L().Warn("Invalid alias file, keeping previous aliases", zap.String("path", a.path), zap.Error(err))
+		return a.entries
+	}
+	a.entries, a.modTime = entries, st.ModTime()
+	zap.L().Info("Loaded title aliases", zap.String("path", a.path), zap.Int("entries", len(entries)))
+	return a.entries
+}
+
+func containsNormalized(list []string, s string) bool {
+	n := indexers.NormalizeQuery(s)
+	for _, v := range list {
+		if indexers.NormalizeQuery(v) == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAliasesKeepPreviousOnInvalidFile(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core)))
+
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	mtime := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// distinct modification times even on coarse filesystem clocks
+		mtime = mtime.Add(time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"Cidade de Deus", "City of God"}
+
+	a := NewAliases(path)
+	write(`[{"pt": "Cidade de Deus", "en": "City of God"}]`)
+	if got := a.Expand("Cidade de Deus"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expand = %q, want %q", got, want)
+	}
+
+	write(`[{"pt": "Cidade de Deus",`)
+	for range 3 {
+		if got := a.Expand("Cidade de Deus"); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expand with an invalid file = %q, want the previous aliases %q", got, want)
+		}
+	}
+	if n := logs.FilterMessage("Invalid alias file, keeping previous aliases").Len(); n != 1 {
+		t.Errorf("warned %d times about the invalid file, want once", n)
+	}
+
+	write(`[{"pt": "Tropa de Elite", "en": "Elite Squad"}]`)
+	if got := a.Expand("Tropa de Elite"); !reflect.DeepEqual(got, []string{"Tropa de Elite", "Elite Squad"}) {
+		t.Errorf("Expand after fixing the file = %q", got)
+	}
+}
@@ -0,0 +1,48 @@
+package search
+
+// Checking a single indexer (admin test endpoint and CLI).
+
+import (
+	"context"
+	"time"
+	"torrProxy/types"
+)
+
+// CheckResult reports an indexer check: the health/login check and, when a
+// query was given, a search.
+type CheckResult struct {
+	OK         bool     `json:"ok"`
+	Health     string   `json:"health"`
+	Query      string   `json:"query,omitempty"`
+	Results    int      `json:"results"`
+	Sample     []string `json:"sample,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// Check runs the health check of idx (if it has one) and, when query is not
+// empty, searches it. The titles of the first results are kept as a sample.
+func Check(ctx context.Context, idx types.Indexer, query string) CheckResult {
+	started := time.Now()
+	res := CheckResult{OK: true, Health: "unsupported", Query: query}
+	if hc, ok := idx.(types.HealthChecker); ok {
+		res.Health = "ok"
+		if err := hc.HealthCheck(ctx); err != nil {
+			res.OK = false
+			res.Health = err.Error()
+		}
+	}
+	if query != "" {
+		results, err := Run(ctx, idx, query, types.Page{})
+		if err != nil {
+			res.OK = false
+			res.Error = err.Error()
+		}
+		res.Results = len(results)
+		for n := 0; n < len(results) && n < 5; n++ {
+			res.Sample = append(res.Sample, results[n].Title)
+		}
+	}
+	res.DurationMS = time.Since(started).Milliseconds()
+	return res
+}
@@ -0,0 +1,188 @@
+package search
+
+// Cross-indexer duplicate merging.
+//
+// The same release is often uploaded to several trackers. Results are merged
+// by info-hash when known and otherwise by normalized release name + size, so
+// clients get one row per release with every source listed.
+
+import (
+	"fmt"
+	"strings"
+
+	"torrProxy/indexers"
+	"torrProxy/types"
+)
+
+// Source is one indexer's copy of a (possibly merged) result.
+type Source struct {
+	Indexer    string `json:"indexer"`
+	Link       string `json:"link,omitempty"`
+	TorrentURL string `json:"torrent_url,omitempty"`
+	Free       bool   `json:"free,omitempty"`
+	Seeders    int    `json:"seeders,omitempty"`
+	Leechers   int    `json:"leechers,omitempty"`
+}
+
+// Item is a result as returned by /search. Source names the indexer the
+// embedded Result came from; Sources lists every indexer that had it once
+// duplicates are merged. Score is the relevance against the query (see Rank).
+type Item struct {
+	types.Result
+	Source  string   `json:"source,omitempty"`
+	Sources []Source `json:"sources,omitempty"`
+	Score   float64  `json:"score,omitempty"`
+}
+
+// DedupeMode selects how duplicates are detected.
+type DedupeMode int
+
+const (
+	// DedupeAll merges by info-hash and by normalized title + size.
+	DedupeAll DedupeMode = iota
+	// DedupeHash merges only results sharing an info-hash.
+	DedupeHash
+	// DedupeOff returns results untouched.
+	DedupeOff
+)
+
+// sizeTolerance is the relative size difference still considered the same release.
+const sizeTolerance = 0.01
+
+// ParseDedupeMode parses the dedupe= query parameter (default: all).
+func ParseDedupeMode(s string) (DedupeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "1", "true", "on", "all":
+		return DedupeAll, nil
+	case "hash":
+		return DedupeHash, nil
+	case "0", "false", "off", "none":
+		return DedupeOff, nil
+	}
+	return DedupeOff, fmt.Errorf("invalid dedupe value %q (use all, hash or off)", s)
+}
+
+// Dedupe merges duplicate items. The first-seen order of releases is kept;
+// the merged item carries the fields of its best source (freeleech first,
+// then most seeders).
+func Dedupe(items []Item, mode DedupeMode) []Item {
+	if mode == DedupeOff {
+		return items
+	}
+
+	out := make([]Item, 0, len(items))
+	byHash := make(map[string]int)
+	byTitle := make(map[string][]int)
+
+	for _, it := range items {
+		if len(it.Sources) == 0 {
+			it.Sources = []Source{sourceOf(it)}
+		}
+		hash := strings.ToLower(it.InfoHash)
+		title := titleKey(it.Title)
+
+		group := -1
+		if hash != "" {
+			if g, ok := byHash[hash]; ok {
+				group = g
+			}
+		}
+		if group < 0 && mode == DedupeAll && title != "" {
+			for _, g := range byTitle[title] {
+				// different known hashes are different torrents, whatever the name
+				gh := strings.ToLower(out[g].InfoHash)
+				if hash != "" && gh != "" && gh != hash {
+					continue
+				}
+				if sameSize(out[g].SizeBytes, it.SizeBytes) {
+					group = g
+					break
+				}
+			}
+		}
+
+		if group < 0 {
+			out = append(out, it)
+			group = len(out) - 1
+			if title != "" {
+				byTitle[title] = append(byTitle[title], group)
+			}
+		} else {
+			out[group] = merge(out[group], it)
+		}
+		if hash != "" {
+			byHash[hash] = group
+		}
+	}
+	return out
+}
+
+func sourceOf(it Item) Source {
+	return Source{
+		Indexer:    it.Source,
+		Link:       it.Link,
+		TorrentURL: it.TorrentURL,
+		Free:       it.Free,
+		Seeders:    it.Seeders,
+		Leechers:   it.Leechers,
+	}
+}
+
+// merge folds b into a, promoting b's fields if it is the better source.
+func merge(a, b Item) Item {
+	// a fresh slice: a.Sources may share its backing array with an input item
+	sources := append(append([]Source(nil), a.Sources...), b.Sources...)
+	best := a
+	if better(b, a) {
+		best = b
+	}
+	best.Sources = sources
+	if best.InfoHash == "" {
+		best.InfoHash = firstNonEmpty(a.InfoHash, b.InfoHash)
+	}
+	if best.SizeBytes == 0 {
+		best.SizeBytes = max(a.SizeBytes, b.SizeBytes)
+	}
+	if best.Description == "" {
+		best.Description = firstNonEmpty(a.Description, b.Description)
+	}
+	if best.PubDate.IsZero() || (!a.PubDate.IsZero() && a.PubDate.Before(best.PubDate)) {
+		best.PubDate = a.PubDate
+	}
+	return best
+}
+
+func better(a, b Item) bool {
+	if a.Free != b.Free {
+		return a.Free
+	}
+	return a.Seeders > b.Seeders
+}
+
+// sameSize reports whether two known sizes match within sizeTolerance; an
+// unknown (zero) size matches nothing.
+func sameSize(a, b int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	diff := float64(a - b)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff/float64(max(a, b)) <= sizeTolerance
+}
+
+// titleKey normalizes a release name like a query, so "Ação.2020.1080p" and
+// "Acao 2020 1080p" compare equal.
+func titleKey(title string) string {
+	return indexers.NormalizeQuery(title)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
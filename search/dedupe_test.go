@@ -0,0 +1,114 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"torrProxy/types"
+)
+
+func item(source, title, hash string, size int64, seeders int, free bool) Item {
+	return Item{
+		Result: types.Result{Title: title, InfoHash: hash, SizeBytes: size, Seeders: seeders, Free: free},
+		Source: source,
+	}
+}
+
+// groups renders the deduped items as the sources of each, e.g. "a+b".
+func groups(items []Item) []string {
+	var out []string
+	for _, it := range items {
+		var names []string
+		for _, s := range it.Sources {
+			names = append(names, s.Indexer)
+		}
+		out = append(out, strings.Join(names, "+"))
+	}
+	return out
+}
+
+func TestDedupe(t *testing.T) {
+	const gb = 1 << 30
+	tests := []struct {
+		name  string
+		mode  DedupeMode
+		items []Item
+		want  []string
+	}{
+		{"same hash, different titles", DedupeAll, []Item{
+			item("a", "Duna 2021 1080p", "ABCDEF", gb, 1, false),
+			item("b", "Dune.2021.1080p.WEB", "abcdef", 2*gb, 1, false),
+		}, []string{"a+b"}},
+		{"title and size", DedupeAll, []Item{
+			item("a", "Ação.2020.1080p", "", gb, 1, false),
+			item("b", "Acao 2020 1080p", "", gb+gb/200, 1, false),
+		}, []string{"a+b"}},
+		{"title, size too different", DedupeAll, []Item{
+			item("a", "Show S01E01", "", gb, 1, false),
+			item("b", "Show S01E01", "", gb+gb/10, 1, false),
+		}, []string{"a", "b"}},
+		{"title and size, different hashes", DedupeAll, []Item{
+			item("a", "Show S01E01", "aaaa", gb, 1, false),
+			item("b", "Show S01E01", "bbbb", gb, 1, false),
+		}, []string{"a", "b"}},
+		{"title and size, one hash known", DedupeAll, []Item{
+			item("a", "Show S01E01", "aaaa", gb, 1, false),
+			item("b", "Show S01E01", "", gb, 1, false),
+			item("c", "Other", "aaaa", 0, 1, false),
+		}, []string{"a+b+c"}},
+		{"title, unknown sizes", DedupeAll, []Item{
+			item("a", "Show S01E01", "", 0, 1, false),
+			item("b", "Show S01E01", "", 0, 1, false),
+		}, []string{"a", "b"}},
+		{"hash mode ignores titles", DedupeHash, []Item{
+			item("a", "Show S01E01", "", gb, 1, false),
+			item("b", "Show S01E01", "", gb, 1, false),
+			item("c", "Show", "cccc", gb, 1, false),
+			item("d", "Show 2", "CCCC", gb, 1, false),
+		}, []string{"a", "b", "c+d"}},
+		{"off", DedupeOff, []Item{
+			item("a", "Show", "cccc", gb, 1, false),
+			item("b", "Show", "cccc", gb, 1, false),
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Dedupe(tt.items, tt.mode)
+			if tt.mode == DedupeOff {
+				if !reflect.DeepEqual(got, tt.items) {
+					t.Errorf("off changed the items: %v", got)
+				}
+				return
+			}
+			if g := groups(got); !reflect.DeepEqual(g, tt.want) {
+				t.Errorf("groups = %q, want %q", g, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupeBestSource(t *testing.T) {
+	got := Dedupe([]Item{
+		item("a", "Show", "h", 100, 50, false),
+		item("b", "Show", "h", 0, 5, true),
+		item("c", "Show", "h", 100, 80, false),
+	}, DedupeAll)
+	if len(got) != 1 {
+		t.Fatalf("got %d items, want 1", len(got))
+	}
+	// freeleech wins over seeders; missing fields come from the others
+	if got[0].Source != "b" || got[0].SizeBytes != 100 {
+		t.Errorf("best = %s with size %d, want b with 100", got[0].Source, got[0].SizeBytes)
+	}
+}
+
+func TestDedupeKeepsInputSources(t *testing.T) {
+	sources := make([]Source, 1, 4)
+	sources[0] = Source{Indexer: "a"}
+	a := item("a", "Show", "h", 100, 1, false)
+	a.Sources = sources
+	Dedupe([]Item{a, item("b", "Show", "h", 100, 1, false)}, DedupeAll)
+	if spare := sources[:2][1]; spare != (Source{}) {
+		t.Errorf("merge wrote %+v into the input's Sources array", spare)
+	}
+}
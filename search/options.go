@@ -0,0 +1,301 @@
+package search
+
+// Server-side filtering, sorting and pagination of the merged result set.
+//
+// /search?q=...&sort=seeders&order=desc&min_seeders=5&min_size=1GB&max_size=8GB
+//        &free=1&resolution=1080p,2160p&language=pt&max_age=7d&min_score=0.5&cat=2000,5040
+//        &limit=50&offset=0
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrProxy/indexers"
+	"torrProxy/types"
+
+	"github.com/coregx/coregex"
+)
+
+// Sort keys accepted by sort=.
+const (
+	SortRelevance = "relevance"
+	SortSeeders   = "seeders"
+	SortSize      = "size"
+	SortPubDate   = "pubdate"
+)
+
+// Options holds the parsed filter/sort/pagination query parameters.
+type Options struct {
+	Sort       string
+	Asc        bool
+	MinSeeders int
+	MinSize    int64
+	MaxSize    int64 // 0 = no upper bound
+	FreeOnly   bool
+	Resolution []string // e.g. 1080p, 2160p
+	Language   []string // pt, dual, sub, en (see languageTags)
+	MaxAge     time.Duration
+	MinScore   float64          // drop results ranked below this relevance (0-1)
+	Categories []types.Category // Newznab IDs; a parent also matches its children
+	Limit      int              // 0 = no limit
+	Offset     int
+}
+
+var (
+	ptAudioRe = coregex.MustCompile(`(?i)\b(dublado|nacional|dual|brazilian|pt-?br|portugu[eê]s)\b`)
+	dualRe    = coregex.MustCompile(`(?i)\bdual\b`)
+	subRe     = coregex.MustCompile(`(?i)\b(legendado|legenda|leg)\b`)
+	ageDaysRe = coregex.MustCompile(`^(\d+)d$`)
+)
+
+// ParseOptions reads filter, sort and pagination parameters from a query string.
+func ParseOptions(q url.Values) (Options, error) {
+	opts := Options{Sort: SortRelevance}
+	var err error
+
+	if v := strings.ToLower(strings.TrimSpace(q.Get("sort"))); v != "" {
+		switch v {
+		case SortRelevance, SortSeeders, SortSize, SortPubDate:
+			opts.Sort = v
+		default:
+			return opts, fmt.Errorf("invalid sort %q (use relevance, seeders, size or pubdate)", v)
+		}
+	}
+	switch strings.ToLower(q.Get("order")) {
+	case "", "desc":
+	case "asc":
+		opts.Asc = true
+	default:
+		return opts, fmt.Errorf("invalid order %q (use asc or desc)", q.Get("order"))
+	}
+
+	if opts.MinSeeders, err = intParam(q, "min_seeders"); err != nil {
+		return opts, err
+	}
+	if opts.MinSize, err = sizeParam(q, "min_size"); err != nil {
+		return opts, err
+	}
+	if opts.MaxSize, err = sizeParam(q, "max_size"); err != nil {
+		return opts, err
+	}
+	if v := q.Get("free"); v != "" {
+		if opts.FreeOnly, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("invalid free %q", v)
+		}
+	}
+	for _, r := range splitList(q.Get("resolution")) {
+		opts.Resolution = append(opts.Resolution, normalizeResolution(r))
+	}
+	opts.Language = splitList(q.Get("language"))
+	if v := q.Get("max_age"); v != "" {
+		if opts.MaxAge, err = parseAge(v); err != nil {
+			return opts, fmt.Errorf("invalid max_age %q", v)
+		}
+	}
+	if v := q.Get("min_score"); v != "" {
+		if opts.MinScore, err = strconv.ParseFloat(v, 64); err != nil || opts.MinScore < 0 || opts.MinScore > 1 {
+			return opts, fmt.Errorf("invalid min_score %q (use 0-1)", v)
+		}
+	}
+	for _, c := range splitList(q.Get("cat")) {
+		id, err := strconv.Atoi(c)
+		if err != nil || id <= 0 {
+			return opts, fmt.Errorf("invalid cat %q", c)
+		}
+		opts.Categories = append(opts.Categories, types.Category(id))
+	}
+	if opts.Limit, err = intParam(q, "limit"); err != nil {
+		return opts, err
+	}
+	if opts.Offset, err = intParam(q, "offset"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// Apply filters and sorts items, then returns the requested page along with
+// the number of items that matched before pagination.
+func Apply(items []Item, opts Options) ([]Item, int) {
+	items = Filter(items, opts)
+	Sort(items, opts)
+	total := len(items)
+	return Paginate(items, opts.Offset, opts.Limit), total
+}
+
+// Filter drops items that do not match opts.
+func Filter(items []Item, opts Options) []Item {
+	out := items[:0:0]
+	for _, it := range items {
+		if it.Seeders < opts.MinSeeders {
+			continue
+		}
+		if opts.MinSize > 0 && it.SizeBytes < opts.MinSize {
+			continue
+		}
+		if opts.MaxSize > 0 && (it.SizeBytes == 0 || it.SizeBytes > opts.MaxSize) {
+			continue
+		}
+		if opts.FreeOnly && !anyFree(it) {
+			continue
+		}
+		if len(opts.Resolution) > 0 && !slices.Contains(opts.Resolution, indexers.Resolution(it.Title)) {
+			continue
+		}
+		if len(opts.Language) > 0 && !anyLanguage(it.Title, opts.Language) {
+			continue
+		}
+		if len(opts.Categories) > 0 && !anyCategory(it.Category, opts.Categories) {
+			continue
+		}
+		if opts.MaxAge > 0 && (it.PubDate.IsZero() || time.Since(it.PubDate) > opts.MaxAge) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// Sort orders items in place.
+func Sort(items []Item, opts Options) {
+	var by func(a, b Item) int
+	switch opts.Sort {
+	case SortRelevance:
+		// Ties broken by seeders so the healthiest copy of a match comes first.
+		by = func(a, b Item) int {
+			if c := cmp.Compare(a.Score, b.Score); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.Seeders, b.Seeders)
+		}
+	case SortSeeders:
+		by = func(a, b Item) int { return cmp.Compare(a.Seeders, b.Seeders) }
+	case SortSize:
+		by = func(a, b Item) int { return cmp.Compare(a.SizeBytes, b.SizeBytes) }
+	case SortPubDate:
+		by = func(a, b Item) int { return a.PubDate.Compare(b.PubDate) }
+	default:
+		return
+	}
+	slices.SortStableFunc(items, func(a, b Item) int {
+		if opts.Asc {
+			return by(a, b)
+		}
+		return by(b, a)
+	})
+}
+
+// Paginate returns items[offset:offset+limit], clamped to the slice bounds.
+func Paginate(items []Item, offset, limit int) []Item {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// languageTags classifies a title's audio/subtitle language from the usual
+// pt-BR release tags: pt (Brazilian audio), dual, sub (legendado) and en
+// (no Portuguese audio tag, i.e. original audio).
+func languageTags(title string) []string {
+	var tags []string
+	if ptAudioRe.MatchString(title) {
+		tags = append(tags, "pt")
+	} else {
+		tags = append(tags, "en")
+	}
+	if dualRe.MatchString(title) {
+		tags = append(tags, "dual")
+	}
+	if subRe.MatchString(title) {
+		tags = append(tags, "sub")
+	}
+	return tags
+}
+
+func anyLanguage(title string, want []string) bool {
+	for _, tag := range languageTags(title) {
+		if slices.Contains(want, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyCategory(c types.Category, want []types.Category) bool {
+	for _, w := range want {
+		if c.Matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyFree(it Item) bool {
+	if it.Free {
+		return true
+	}
+	for _, s := range it.Sources {
+		if s.Free {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeResolution(r string) string {
+	r = strings.ToLower(strings.TrimSpace(r))
+	if r == "4k" || r == "uhd" {
+		return "2160p"
+	}
+	return r
+}
+
+func parseAge(s string) (time.Duration, error) {
+	if m := ageDaysRe.FindStringSubmatch(s); len(m) == 2 {
+		days, _ := strconv.Atoi(m[1])
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func intParam(q url.Values, key string) (int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return i, nil
+}
+
+func sizeParam(q url.Values, key string) (int64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n := indexers.ParseSize(v)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+	"torrProxy/types"
+)
+
+// optionItems are ranked a, d, b, c by relevance.
+func optionItems() []Item {
+	const gb = 1 << 30
+	now := time.Now()
+	at := func(it Item, score float64, age time.Duration, cat types.Category) Item {
+		it.Score = score
+		if age > 0 {
+			it.PubDate = now.Add(-age)
+		}
+		it.Category = cat
+		return it
+	}
+	return []Item{
+		at(item("a", "Show S01E01 1080p DUAL", "", 2*gb, 10, false), 0.9, time.Hour, types.CategoryTVHD),
+		at(item("b", "Show S01E01 720p LEGENDADO", "", gb/2, 50, true), 0.5, 48*time.Hour, types.CategoryTVSD),
+		at(item("c", "Movie 2160p", "", 0, 0, false), 0.1, 0, types.CategoryMoviesUHD),
+		at(item("d", "Movie 1080p Dublado", "", 8*gb, 5, false), 0.7, 10*24*time.Hour, types.CategoryMovies),
+	}
+}
+
+func sources(items []Item) []string {
+	var out []string
+	for _, it := range items {
+		out = append(out, it.Source)
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+		total int
+	}{
+		{"", []string{"a", "d", "b", "c"}, 4},
+
+		// filters
+		{"min_seeders=5", []string{"a", "d", "b"}, 3},
+		{"min_size=1GB", []string{"a", "d"}, 2},
+		{"max_size=4GB", []string{"a", "b"}, 2},
+		{"min_size=1GB&max_size=4GB", []string{"a"}, 1},
+		{"free=1", []string{"b"}, 1},
+		{"resolution=4k", []string{"c"}, 1},
+		{"resolution=1080p,720p", []string{"a", "d", "b"}, 3},
+		{"language=pt", []string{"a", "d"}, 2},
+		{"language=sub", []string{"b"}, 1},
+		{"language=dual,sub", []string{"a", "b"}, 2},
+		{"max_age=7d", []string{"a", "b"}, 2},
+		{"cat=5000", []string{"a", "b"}, 2},
+		{"cat=2045,5030", []string{"b", "c"}, 2},
+		{"min_seeders=100", nil, 0},
+
+		// sorting
+		{"sort=seeders", []string{"b", "a", "d", "c"}, 4},
+		{"sort=seeders&order=asc", []string{"c", "d", "a", "b"}, 4},
+		{"sort=size&order=asc", []string{"c", "b", "a", "d"}, 4},
+		{"sort=pubdate", []string{"a", "b", "d", "c"}, 4},
+		{"sort=relevance&order=asc", []string{"c", "b", "d", "a"}, 4},
+
+		// pagination
+		{"limit=2", []string{"a", "d"}, 4},
+		{"limit=0", []string{"a", "d", "b", "c"}, 4},
+		{"offset=1&limit=2", []string{"d", "b"}, 4},
+		{"offset=3&limit=5", []string{"c"}, 4},
+		{"offset=4", nil, 4},
+		{"offset=10&limit=2", nil, 4},
+		{"min_seeders=5&sort=seeders&offset=1&limit=1", []string{"a"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts, err := ParseOptions(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, total := Apply(optionItems(), opts)
+			if got := sources(page); !reflect.DeepEqual(got, tt.want) || total != tt.total {
+				t.Errorf("got %q (total %d), want %q (total %d)", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	q, _ := url.ParseQuery("sort=SIZE&order=asc&min_seeders=3&min_size=1GB&free=true&resolution=UHD,720p" +
+		"&language=pt,dual&max_age=36h&min_score=0.5&cat=2000,5040&limit=20&offset=40")
+	got, err := ParseOptions(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{
+		Sort:       SortSize,
+		Asc:        true,
+		MinSeeders: 3,
+		MinSize:    1 << 30,
+		FreeOnly:   true,
+		Resolution: []string{"2160p", "720p"},
+		Language:   []string{"pt", "dual"},
+		MaxAge:     36 * time.Hour,
+		MinScore:   0.5,
+		Categories: []types.Category{types.CategoryMovies, types.CategoryTVHD},
+		Limit:      20,
+		Offset:     40,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOptions = %+v, want %+v", got, want)
+	}
+
+	if got, _ := ParseOptions(url.Values{}); got.Sort != SortRelevance || got.Asc || got.Limit != 0 {
+		t.Errorf("defaults = %+v, want relevance, descending, no limit", got)
+	}
+	if got, _ := ParseOptions(url.Values{"max_age": {"7d"}}); got.MaxAge != 7*24*time.Hour {
+		t.Errorf("max_age=7d = %v, want 168h", got.MaxAge)
+	}
+}
+
+func TestParseOptionsErrors(t *testing.T) {
+	for _, query := range []string{
+		"sort=name",
+		"order=up",
+		"min_seeders=-1",
+		"min_size=big",
+		"max_size=0",
+		"free=maybe",
+		"max_age=week",
+		"min_score=2",
+		"cat=tv",
+		"cat=0",
+		"limit=x",
+		"offset=-2",
+	} {
+		q, _ := url.ParseQuery(query)
+		if _, err := ParseOptions(q); err == nil {
+			t.Errorf("ParseOptions(%q) succeeded, want an error", query)
+		}
+	}
+}
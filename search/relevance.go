@@ -0,0 +1,143 @@
+package search
+
+// Relevance scoring of results against the search query.
+//
+// Trackers run fuzzy searches and return plenty of unrelated releases. Each
+// result is scored by the share of query tokens found in its title (or its
+// original/alternate title, carried in Description), adjusted for
+// season/episode agreement, so the list can be ranked and junk dropped.
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"torrProxy/indexers"
+
+	"github.com/coregx/coregex"
+)
+
+var (
+	episodeRe   = coregex.MustCompile(`(?i)\bs(\d{1,2})e(\d{1,3})\b`)
+	seasonRe    = coregex.MustCompile(`(?i)\bs(\d{1,2})\b`)
+	temporadaRe = coregex.MustCompile(`(?i)\b(\d{1,2})[ªa]?\s*temporada\b|\btemporada\s*(\d{1,2})\b`)
+)
+
+// stopwords are ignored when matching; trackers drop them inconsistently.
+var stopwords = map[string]bool{
+	"a": true, "o": true, "as": true, "os": true, "de": true, "do": true, "da": true,
+	"dos": true, "das": true, "e": true, "em": true, "the": true, "of": true,
+	"and": true, "an": true, "in": true,
+}
+
+// episodeRef is a season/episode reference; 0 means absent.
+type episodeRef struct {
+	season, episode int
+}
+
+// Score rates how well title (or alt, usually the original title) matches
+// query, from 0 (unrelated) to 1 (every query token present, same episode).
+func Score(query, title, alt string) float64 {
+	qTokens, qRef := queryTokens(query)
+	s := tokenScore(qTokens, title)
+	if alt != "" {
+		s = max(s, tokenScore(qTokens, alt))
+	}
+	return s * episodeFactor(qRef, parseEpisode(title))
+}
+
+// Rank scores every item against the queries (the search and its aliases),
+// keeping the best score, and drops those below minScore.
+func Rank(items []Item, queries []string, minScore float64) []Item {
+	out := items[:0:0]
+	for _, it := range items {
+		it.Score = 0
+		for _, q := range queries {
+			it.Score = max(it.Score, Score(q, it.Title, it.Description))
+		}
+		if it.Score < minScore {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// queryTokens splits the query into match tokens, pulling out the
+// season/episode marker so "Show S01E02" matches "Show.S01E02.1080p".
+func queryTokens(query string) ([]string, episodeRef) {
+	ref := parseEpisode(query)
+	query = episodeRe.ReplaceAllString(query, " ")
+	query = seasonRe.ReplaceAllString(query, " ")
+	query = temporadaRe.ReplaceAllString(query, " ")
+	var out []string
+	for _, t := range tokenize(query) {
+		if !stopwords[t] {
+			out = append(out, t)
+		}
+	}
+	return out, ref
+}
+
+func tokenScore(qTokens []string, title string) float64 {
+	if len(qTokens) == 0 {
+		return 1
+	}
+	have := make(map[string]bool)
+	for _, t := range tokenize(title) {
+		have[t] = true
+	}
+	matched := 0
+	for _, t := range qTokens {
+		if have[t] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(qTokens))
+}
+
+// episodeFactor scales a score by season/episode agreement: exact or season
+// pack matches keep it, a different episode or season is heavily penalized.
+func episodeFactor(q, t episodeRef) float64 {
+	if q.season == 0 {
+		return 1
+	}
+	switch {
+	case t.season == 0:
+		// No marker in the title: could be a pack named differently.
+		return 0.6
+	case t.season != q.season:
+		return 0.1
+	case q.episode == 0 || t.episode == q.episode:
+		return 1
+	case t.episode == 0:
+		// Season pack when asking for one episode.
+		return 0.9
+	}
+	return 0.3
+}
+
+func parseEpisode(s string) episodeRef {
+	if m := episodeRe.FindStringSubmatch(s); len(m) == 3 {
+		se, _ := strconv.Atoi(m[1])
+		ep, _ := strconv.Atoi(m[2])
+		return episodeRef{season: se, episode: ep}
+	}
+	if m := seasonRe.FindStringSubmatch(s); len(m) == 2 {
+		se, _ := strconv.Atoi(m[1])
+		return episodeRef{season: se}
+	}
+	if m := temporadaRe.FindStringSubmatch(s); len(m) == 3 {
+		se, _ := strconv.Atoi(m[1] + m[2])
+		return episodeRef{season: se}
+	}
+	return episodeRef{}
+}
+
+// tokenize lowercases, folds accents and splits on anything that is not a
+// letter or digit.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(indexers.FoldAccents(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"torrProxy/indexers"
+	"torrProxy/logging"
+	"torrProxy/metrics"
+	"torrProxy/tracing"
+	"torrProxy/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// SelectIndexers acquires the indexers of reg whose ids are in a
+// comma-separated list, or all of them when the list is empty. Call release
+// once the request is done with them (see types.Registry.Acquire).
+func SelectIndexers(reg *types.Registry, list string) (idxs []types.Indexer, release func()) {
+	var names []string
+	for _, nm := range strings.Split(list, ",") {
+		if nm = strings.TrimSpace(nm); nm != "" {
+			names = append(names, nm)
+		}
+	}
+	return reg.AcquireIDs(names...)
+}
+
+// Search queries every indexer in parallel with each query (the search and
+// its aliases) and flattens the results. page is passed to indexers that
+// paginate (types.PagedIndexer). Failed indexers are reported as "name: error".
+func Search(ctx context.Context, idxs []types.Indexer, queries []string, page types.Page) ([]Item, []string) {
+	return fanout(ctx, idxs, func(ctx context.Context, idx types.Indexer) ([]types.Result, error) {
+		return RunAll(ctx, idx, queries, page)
+	})
+}
+
+// Collect fans q (expanded with its aliases) out to idxs, or lists their
+// newest torrents when q is empty, then merges and ranks. Indexers are asked
+// for enough results to fill the page selected by opts.
+func Collect(ctx context.Context, idxs []types.Indexer, aliases *Aliases, q string, dedupe DedupeMode, opts Options) ([]Item, []string) {
+	if q == "" {
+		flat, errs := Recent(ctx, idxs)
+		return Dedupe(flat, dedupe), errs
+	}
+	queries := aliases.Expand(q)
+	page := types.Page{Limit: opts.Offset + opts.Limit}
+	flat, errs := Search(ctx, idxs, queries, page)
+	flat = Dedupe(flat, dedupe)
+	return Rank(flat, queries, opts.MinScore), errs
+}
+
+// Recent lists the newest torrents of every indexer implementing
+// types.RecentIndexer; the others are skipped.
+func Recent(ctx context.Context, idxs []types.Indexer) ([]Item, []string) {
+	var recent []types.Indexer
+	for _, idx := range idxs {
+		if _, ok := idx.(types.RecentIndexer); ok {
+			recent = append(recent, idx)
+		}
+	}
+	return fanout(ctx, recent, func(ctx context.Context, idx types.Indexer) ([]types.Result, error) {
+		return idx.(types.RecentIndexer).Recent(ctx)
+	})
+}
+
+func fanout(ctx context.Context, idxs []types.Indexer, fn func(context.Context, types.Indexer) ([]types.Result, error)) ([]Item, []string) {
+	type backendResp struct {
+		Indexer string
+		Results []types.Result
+		Error   string
+	}
+	ch := make(chan backendResp, len(idxs))
+
+	// query backends in parallel
+	for _, idx := range idxs {
+		go func(idx types.Indexer) {
+			ctx, span := tracing.Start(ctx, "indexer.search", trace.WithAttributes(attribute.String("indexer", idx.Id())))
+			start := time.Now()
+			results, err := fn(ctx, idx)
+			metrics.ObserveSearch(idx.Id(), time.Since(start), len(results), err)
+			span.SetAttributes(attribute.Int("results", len(results)))
+			tracing.End(span, err)
+			br := backendResp{Indexer: idx.Name(), Results: results}
+			if err != nil {
+				br.Error = err.Error()
+				logging.L(ctx).Warn("Indexer failed", zap.String("indexer", idx.Id()), zap.Error(err))
+			} else {
+				logging.L(ctx).Debug("Indexer answered", zap.String("indexer", idx.Id()),
+					zap.Int("results", len(results)), zap.Duration("duration", time.Since(start)))
+			}
+			ch <- br
+		}(idx)
+	}
+
+	// collect and flatten
+	flat := make([]Item, 0)
+	var errs []string
+	for i := 0; i < len(idxs); i++ {
+		br := <-ch
+		if br.Error != "" {
+			errs = append(errs, br.Indexer+": "+br.Error)
+			continue
+		}
+		for _, r := range br.Results {
+			flat = append(flat, Item{Result: r, Source: br.Indexer})
+		}
+	}
+	return flat, errs
+}
+
+// Run searches idx with the normalized query and, while nothing is found,
+// retries with the alternate spellings from indexers.QueryVariants. An error
+// is only returned if no variant produced results.
+func Run(ctx context.Context, idx types.Indexer, query string, page types.Page) ([]types.Result, error) {
+	var firstErr error
+	for _, q := range indexers.QueryVariants(query) {
+		results, err := searchPage(ctx, idx, q, page)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	return nil, firstErr
+}
+
+// RunAll runs every query (typically the alias expansion of one search)
+// against idx and merges the results, dropping torrents already returned by
+// an earlier query. It fails unless at least one query succeeded, so an
+// indexer that times out is not reported as having found nothing.
+func RunAll(ctx context.Context, idx types.Indexer, queries []string, page types.Page) ([]types.Result, error) {
+	var out []types.Result
+	var firstErr error
+	succeeded := 0
+	seen := make(map[string]bool)
+	for _, q := range queries {
+		results, err := Run(ctx, idx, q, page)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		succeeded++
+		for _, r := range results {
+			key := r.TorrentURL
+			if key == "" {
+				key = r.Link
+			}
+			if key != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, r)
+		}
+	}
+	if succeeded == 0 {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+func searchPage(ctx context.Context, idx types.Indexer, query string, page types.Page) ([]types.Result, error) {
+	if p, ok := idx.(types.PagedIndexer); ok {
+		return p.SearchPage(ctx, query, page)
+	}
+	return idx.Search(ctx, query)
+}
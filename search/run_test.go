@@ -0,0 +1,55 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"torrProxy/indexers"
+	"torrProxy/types"
+)
+
+func TestRunAll(t *testing.T) {
+	aliased := []string{"Cidade de Deus", "City of God"}
+	tests := []struct {
+		name    string
+		idx     *indexers.Fake
+		timeout time.Duration
+		want    int
+		wantErr error
+	}{
+		{"results", &indexers.Fake{Count: 3}, time.Second, 6, nil},
+		{"nothing found", &indexers.Fake{Count: 0}, time.Second, 0, nil},
+		{"timeout", &indexers.Fake{Count: 3, Latency: time.Second}, 20 * time.Millisecond, 0, context.DeadlineExceeded},
+		{"failing", &indexers.Fake{Count: 3, FailureRate: 1}, time.Second, 0, errors.New("fake: simulated failure")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			results, err := RunAll(ctx, tt.idx, aliased, types.Page{})
+			if len(results) != tt.want {
+				t.Errorf("got %d results, want %d", len(results), tt.want)
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()):
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearchReportsTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	idxs := []types.Indexer{&indexers.Fake{ID: "slow", Count: 3, Latency: time.Second}, &indexers.Fake{ID: "quick", Count: 2}}
+	items, errs := Search(ctx, idxs, []string{"a", "b"}, types.Page{})
+	if len(items) != 4 {
+		t.Errorf("got %d items, want the 4 of quick", len(items))
+	}
+	if len(errs) != 1 || errs[0] != "Fake: context deadline exceeded" {
+		t.Errorf("errs = %q, want the timeout of slow", errs)
+	}
+}
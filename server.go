@@ -0,0 +1,166 @@
+package main
+
+// HTTP serving: listeners (TCP and Unix sockets), TLS with certificate
+// reload, and graceful shutdown.
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"torrProxy/config"
+
+	"go.uber.org/zap"
+)
+
+// certCheckInterval is how often the TLS files are checked for changes.
+const certCheckInterval = 10 * time.Second
+
+// listenAndServe serves srv on every address of sc until ctx is done or a
+// listener fails, then shuts down gracefully: listeners close at once and
+// in-flight requests get up to sc.ShutdownTimeout to finish.
+func listenAndServe(ctx context.Context, srv *http.Server, sc config.Server) error {
+	if sc.TLSCert != "" {
+		cr, err := newCertReloader(sc.TLSCert, sc.TLSKey)
+		if err != nil {
+			return err
+		}
+		// listing h2 here (rather than leaving it to ServeTLS) keeps HTTP/2
+		// enabled when plain Unix listeners share the server
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			NextProtos:     []string{"h2", "http/1.1"},
+			GetCertificate: cr.GetCertificate,
+		}
+	}
+
+	var listeners []net.Listener
+	for _, addr := range sc.Addresses() {
+		l, err := listen(addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		tlsOn := srv.TLSConfig != nil && l.Addr().Network() != "unix"
+		zap.L().Info("Listening", zap.String("network", l.Addr().Network()), zap.String("addr", l.Addr().String()), zap.Bool("tls", tlsOn))
+		if tlsOn {
+			l = tls.NewListener(l, srv.TLSConfig)
+		}
+		go func() { errc <- srv.Serve(l) }()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		zap.L().Info("Shutting down; waiting for in-flight requests", zap.Duration("timeout", sc.ShutdownTimeout))
+	case err = <-errc:
+		zap.L().Error("Listener failed; shutting down", zap.Error(err))
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), sc.ShutdownTimeout)
+	defer cancel()
+	if serr := srv.Shutdown(sctx); serr != nil {
+		zap.L().Warn("Requests still running at shutdown timeout were cut off", zap.Error(serr))
+		_ = srv.Close()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// listen opens a TCP address or, for "unix:/path", a Unix socket. A stale
+// socket file left by a crashed process is removed first.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("listen %s: socket in use", addr)
+		}
+		_ = os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
+// certReloader serves the certificate in certFile/keyFile, loading it again
+// when either file changes (e.g. after a renewal).
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // latest modification time of the two files
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) load() error {
+	mod, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("server.tls_cert: %w", err)
+	}
+	cr.cert, cr.modTime = &cert, mod
+	return nil
+}
+
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A certificate that
+// fails to load is logged and the previous one kept.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if time.Since(cr.checked) < certCheckInterval {
+		return cr.cert, nil
+	}
+	cr.checked = time.Now()
+	if mod, err := cr.latestModTime(); err == nil && !mod.Equal(cr.modTime) {
+		if err := cr.load(); err != nil {
+			zap.L().Error("Failed to reload TLS certificate; keeping the current one", zap.Error(err))
+		} else {
+			zap.L().Info("Reloaded TLS certificate", zap.String("cert", cr.certFile))
+		}
+	}
+	return cr.cert, nil
+}
@@ -0,0 +1,102 @@
+package tracing_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"torrProxy/config"
+	"torrProxy/indexers"
+	"torrProxy/search"
+	"torrProxy/tracing"
+	"torrProxy/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an OTLP/HTTP trace receiver keeping the spans it is sent.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	out, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	_, _ = w.Write(out)
+}
+
+func TestSearchSpans(t *testing.T) {
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	shutdown, err := tracing.Setup(context.Background(), config.Tracing{Enabled: true, Endpoint: srv.URL, ServiceName: "torrProxy-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	idxs := []types.Indexer{&indexers.Fake{ID: "fake", Count: 2}, &indexers.Fake{ID: "other", Count: 1}}
+	if _, errs := search.Search(context.Background(), idxs, []string{"duna"}, types.Page{}); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	// flushes the batched spans to the collector
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	for _, s := range col.spans {
+		if s.Name != "indexer.search" {
+			continue
+		}
+		for _, a := range s.Attributes {
+			if a.Key == "indexer" {
+				got[a.Value.GetStringValue()] = true
+			}
+		}
+	}
+	if !got["fake"] || !got["other"] || len(got) != 2 {
+		t.Errorf("indexer.search spans for %v, want fake and other (all spans: %d)", got, len(col.spans))
+	}
+}
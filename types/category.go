@@ -0,0 +1,98 @@
+package types
+
+// Category is a Newznab/Torznab category ID. Parents are multiples of 1000
+// (5000 TV) and children refine them (5040 TV/HD).
+type Category int
+
+const (
+	CategoryConsole Category = 1000
+
+	CategoryMovies        Category = 2000
+	CategoryMoviesForeign Category = 2010
+	CategoryMoviesSD      Category = 2030
+	CategoryMoviesHD      Category = 2040
+	CategoryMoviesUHD     Category = 2045
+	CategoryMovies3D      Category = 2060
+
+	CategoryAudio Category = 3000
+
+	CategoryPC      Category = 4000
+	CategoryPCGames Category = 4050
+
+	CategoryTV            Category = 5000
+	CategoryTVForeign     Category = 5020
+	CategoryTVSD          Category = 5030
+	CategoryTVHD          Category = 5040
+	CategoryTVUHD         Category = 5045
+	CategoryTVAnime       Category = 5070
+	CategoryTVDocumentary Category = 5080
+
+	CategoryXXX Category = 6000
+
+	CategoryBooks       Category = 7000
+	CategoryBooksEBook  Category = 7020
+	CategoryBooksComics Category = 7030
+
+	CategoryOther Category = 8000
+)
+
+var categoryNames = map[Category]string{
+	CategoryConsole:       "Console",
+	CategoryMovies:        "Movies",
+	CategoryMoviesForeign: "Movies/Foreign",
+	CategoryMoviesSD:      "Movies/SD",
+	CategoryMoviesHD:      "Movies/HD",
+	CategoryMoviesUHD:     "Movies/UHD",
+	CategoryMovies3D:      "Movies/3D",
+	CategoryAudio:         "Audio",
+	CategoryPC:            "PC",
+	CategoryPCGames:       "PC/Games",
+	CategoryTV:            "TV",
+	CategoryTVForeign:     "TV/Foreign",
+	CategoryTVSD:          "TV/SD",
+	CategoryTVHD:          "TV/HD",
+	CategoryTVUHD:         "TV/UHD",
+	CategoryTVAnime:       "TV/Anime",
+	CategoryTVDocumentary: "TV/Documentary",
+	CategoryXXX:           "XXX",
+	CategoryBooks:         "Books",
+	CategoryBooksEBook:    "Books/EBook",
+	CategoryBooksComics:   "Books/Comics",
+	CategoryOther:         "Other",
+}
+
+// AllCategories lists every known category, parents before their children.
+var AllCategories = []Category{
+	CategoryConsole,
+	CategoryMovies, CategoryMoviesForeign, CategoryMoviesSD, CategoryMoviesHD, CategoryMoviesUHD, CategoryMovies3D,
+	CategoryAudio,
+	CategoryPC, CategoryPCGames,
+	CategoryTV, CategoryTVForeign, CategoryTVSD, CategoryTVHD, CategoryTVUHD, CategoryTVAnime, CategoryTVDocumentary,
+	CategoryXXX,
+	CategoryBooks, CategoryBooksEBook, CategoryBooksComics,
+	CategoryOther,
+}
+
+// Name returns the Newznab name ("TV/HD"), or "" for unknown IDs.
+func (c Category) Name() string {
+	return categoryNames[c]
+}
+
+// Parent returns the top-level category (5040 -> 5000).
+func (c Category) Parent() Category {
+	return c / 1000 * 1000
+}
+
+// IsParent reports whether c is a top-level category.
+func (c Category) IsParent() bool {
+	return c != 0 && c%1000 == 0
+}
+
+// Matches reports whether c is want or one of its children, so asking for
+// 5000 matches 5040 but asking for 5040 does not match 5030.
+func (c Category) Matches(want Category) bool {
+	if c == 0 {
+		return false
+	}
+	return c == want || (want.IsParent() && c.Parent() == want)
+}
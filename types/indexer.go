@@ -3,7 +3,6 @@ package types
 import (
 	"context"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -16,11 +15,13 @@ type Result struct {
 	Description string    `json:"description,omitempty"`
 	Free        bool      `json:"free,omitempty"`
 	Size        string    `json:"size,omitempty"`
+	SizeBytes   int64     `json:"size_bytes,omitempty"`
 	PubDate     time.Time `json:"pubdate,omitempty"`
 	Seeders     int       `json:"seeders,omitempty"`
 	Leechers    int       `json:"leechers,omitempty"`
 	InfoHash    string    `json:"infohash,omitempty"`
 	TorrentURL  string    `json:"torrent_url,omitempty"`
+	Category    Category  `json:"category,omitempty"`
 }
 
 // Indexer is the interface all indexers implement.
@@ -31,7 +32,42 @@ type Indexer interface {
 	Search(ctx context.Context, query string) ([]Result, error)
 }
 
-var Indexers []Indexer
+// Page is a window [Offset, Offset+Limit) over an indexer's results. A zero
+// Limit means "whatever the tracker returns on one page".
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// PagedIndexer is implemented by indexers that can walk tracker pagination.
+// Search(ctx, q) behaves like SearchPage(ctx, q, Page{}).
+type PagedIndexer interface {
+	SearchPage(ctx context.Context, query string, page Page) ([]Result, error)
+}
+
+// Initializer is implemented by indexers that need setup before serving,
+// such as a tracker login. Init is called once, after construction.
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// HealthChecker is implemented by indexers that can cheaply verify the
+// tracker is reachable and the session or API key is still valid.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Closer is implemented by indexers holding resources (sessions, idle
+// connections) to release when the instance is removed.
+type Closer interface {
+	Close() error
+}
+
+// RecentIndexer is implemented by indexers that can list their newest
+// torrents without a query (RSS sync).
+type RecentIndexer interface {
+	Recent(ctx context.Context) ([]Result, error)
+}
 
 func ToString(v interface{}) string {
 	if v == nil {
@@ -52,31 +88,3 @@ func ToString(v interface{}) string {
 		return string(b)
 	}
 }
-
-// FindIndexer find an indexer by id (case-insensitive match on Id() or Name()).
-func FindIndexer(idOrName string) Indexer {
-	if idOrName == "" {
-		return nil
-	}
-	lower := strings.ToLower(idOrName)
-	for _, idx := range Indexers {
-		// prefer ID if implemented
-		if strings.ToLower(getIndexerID(idx)) == lower || strings.ToLower(idx.Name()) == lower {
-			return idx
-		}
-	}
-	return nil
-}
-
-// getIndexerID returns idx.Id() if available via type assertion, else Name().
-func getIndexerID(idx Indexer) string {
-	type hasId interface {
-		Id() string
-	}
-	if h, ok := idx.(hasId); ok {
-		if id := strings.TrimSpace(h.Id()); id != "" {
-			return id
-		}
-	}
-	return idx.Name()
-}
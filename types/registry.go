@@ -0,0 +1,208 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Registry holds the running indexer instances, in configuration order.
+// It is safe for concurrent use.
+//
+// Requests take the instances they use with Acquire and hand them back with
+// the returned release func, so Swap can replace instances while searches
+// run: retired instances are closed only once their last request finishes.
+type Registry struct {
+	mu      sync.RWMutex
+	entries []*entry
+}
+
+type entry struct {
+	idx      Indexer
+	inflight sync.WaitGroup
+}
+
+// NewRegistry returns a registry holding idxs.
+func NewRegistry(idxs ...Indexer) *Registry {
+	r := &Registry{}
+	for _, idx := range idxs {
+		_ = r.Add(idx)
+	}
+	return r
+}
+
+// Add registers idx. Ids must be unique (case-insensitive).
+func (r *Registry) Add(idx Indexer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.find(idx.Id()) != nil {
+		return fmt.Errorf("indexer %q already registered", idx.Id())
+	}
+	r.entries = append(r.entries, &entry{idx: idx})
+	return nil
+}
+
+// All returns a snapshot of the registered indexers.
+func (r *Registry) All() []Indexer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Indexer, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e.idx)
+	}
+	return out
+}
+
+// IDs returns the ids of the registered indexers.
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		ids = append(ids, e.idx.Id())
+	}
+	return ids
+}
+
+// Find returns the indexer whose Id() or Name() matches idOrName
+// (case-insensitive), or nil. The instance is not held; use Acquire while
+// serving a request.
+func (r *Registry) Find(idOrName string) Indexer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e := r.find(idOrName); e != nil {
+		return e.idx
+	}
+	return nil
+}
+
+// Acquire returns the indexers matching names (ids or names, see Find), or
+// all of them when names is empty, and holds them until release is called.
+// Unknown names are skipped.
+func (r *Registry) Acquire(names ...string) (idxs []Indexer, release func()) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var held []*entry
+	if len(names) == 0 {
+		held = append(held, r.entries...)
+	}
+	for _, nm := range names {
+		if e := r.find(nm); e != nil {
+			held = append(held, e)
+		}
+	}
+	return r.hold(held)
+}
+
+// AcquireIDs is Acquire matching ids exactly, without falling back to names.
+func (r *Registry) AcquireIDs(ids ...string) (idxs []Indexer, release func()) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var held []*entry
+	if len(ids) == 0 {
+		held = append(held, r.entries...)
+	}
+	for _, id := range ids {
+		for _, e := range r.entries {
+			if e.idx.Id() == id {
+				held = append(held, e)
+				break
+			}
+		}
+	}
+	return r.hold(held)
+}
+
+// hold marks held as in use; r.mu must be held.
+func (r *Registry) hold(held []*entry) (idxs []Indexer, release func()) {
+	for _, e := range held {
+		e.inflight.Add(1)
+		idxs = append(idxs, e.idx)
+	}
+	var once sync.Once
+	return idxs, func() {
+		once.Do(func() {
+			for _, e := range held {
+				e.inflight.Done()
+			}
+		})
+	}
+}
+
+func (r *Registry) find(idOrName string) *entry {
+	idOrName = strings.TrimSpace(idOrName)
+	if idOrName == "" {
+		return nil
+	}
+	// prefer an id match over a name match
+	for _, e := range r.entries {
+		if strings.EqualFold(e.idx.Id(), idOrName) {
+			return e
+		}
+	}
+	for _, e := range r.entries {
+		if strings.EqualFold(e.idx.Name(), idOrName) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Swap atomically replaces the registered indexers with idxs; instances
+// present before and after keep their in-flight requests. The returned
+// drain func blocks until the removed instances have no requests left,
+// then closes those implementing Closer.
+func (r *Registry) Swap(idxs []Indexer) (drain func() error) {
+	r.mu.Lock()
+	next := make([]*entry, 0, len(idxs))
+	var retired []*entry
+	for _, idx := range idxs {
+		next = append(next, r.entryOf(idx))
+	}
+	for _, e := range r.entries {
+		if !containsEntry(next, e) {
+			retired = append(retired, e)
+		}
+	}
+	r.entries = next
+	r.mu.Unlock()
+
+	return func() error {
+		var errs []error
+		for _, e := range retired {
+			e.inflight.Wait()
+			if c, ok := e.idx.(Closer); ok {
+				if err := c.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", e.idx.Id(), err))
+				}
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// entryOf returns the current entry of idx, or a new one.
+func (r *Registry) entryOf(idx Indexer) *entry {
+	for _, e := range r.entries {
+		if e.idx == idx {
+			return e
+		}
+	}
+	return &entry{idx: idx}
+}
+
+func containsEntry(entries []*entry, e *entry) bool {
+	for _, x := range entries {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Close empties the registry, waits for in-flight requests and closes every
+// indexer implementing Closer.
+func (r *Registry) Close() error {
+	return r.Swap(nil)()
+}